@@ -0,0 +1,42 @@
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Backend starts targets to capture.
+type Backend interface {
+	// Start starts image. The returned Instance must be closed, even if the capture fails.
+	Start(ctx context.Context, image string, log io.Writer) (Instance, error)
+}
+
+// Instance is a started target.
+type Instance interface {
+	// Transport returns the command prefix to run a command in the instance with stdio.
+	Transport() []string
+	// Sudo is whether make-wire must be run under sudo.
+	Sudo() bool
+	// Close stops and removes the instance.
+	Close(ctx context.Context) error
+}
+
+// Backends are the known backends by name.
+var Backends = map[string]Backend{
+	"docker":    Docker{},
+	"multipass": Multipass{},
+}
+
+func run(ctx context.Context, log io.Writer, name string, args ...string) (string, error) {
+	fmt.Fprintf(log, "+ %s %s\n", name, strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = log
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
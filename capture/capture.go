@@ -0,0 +1,268 @@
+// Package capture runs captures of many targets, such as container images and VMs.
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/config"
+	"github.com/nyiyui/opt/hinomori/remote"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Manifest lists targets to capture.
+type Manifest struct {
+	// Config is the path to a config with profiles, relative to the manifest.
+	// Empty means the built-in profiles.
+	Config  string   `json:"config"`
+	Targets []Target `json:"targets"`
+
+	config *config.Config
+}
+
+// Target is a single image to capture.
+type Target struct {
+	// Name is used for output file names, with / and : replaced by _. Defaults to Image.
+	Name    string   `json:"name"`
+	Image   string   `json:"image"`
+	Backend string   `json:"backend"`
+	Root    string   `json:"root"`
+	Profile string   `json:"profile"`
+	Block   []string `json:"block"`
+	Hash    []string `json:"hash"`
+	HashAll bool     `json:"hashAll"`
+}
+
+// nameReplacer makes image names (e.g. ghcr.io/x/y:tag) usable in file names.
+var nameReplacer = strings.NewReplacer("/", "_", ":", "_")
+
+// LoadManifest reads a manifest in JSON.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, err
+	}
+	m.config = config.Default()
+	if m.Config != "" {
+		m.config, err = config.Load(filepath.Join(filepath.Dir(path), m.Config))
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	names := map[string]bool{}
+	for i := range m.Targets {
+		t := &m.Targets[i]
+		if t.Name == "" {
+			t.Name = t.Image
+		}
+		t.Name = nameReplacer.Replace(t.Name)
+		if names[t.Name] {
+			return nil, fmt.Errorf("target %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.Root == "" {
+			t.Root = "/"
+		}
+		if _, ok := Backends[t.Backend]; !ok {
+			return nil, fmt.Errorf("target %s: unknown backend %q", t.Name, t.Backend)
+		}
+	}
+	return &m, nil
+}
+
+// Status is the outcome of capturing a Target, written alongside its output.
+type Status struct {
+	Name       string `json:"name"`
+	ExitStatus int    `json:"exitStatus"`
+	Error      string `json:"error,omitempty"`
+	Files      int    `json:"files"`
+	Duration   string `json:"duration"`
+}
+
+// Capturer captures targets into OutDir.
+type Capturer struct {
+	OutDir string
+	// Parallel is the maximum number of concurrent captures.
+	Parallel int
+	// BinDir contains make-wire binaries named make-wire-<goos>-<goarch>.
+	BinDir string
+	// Backends overrides the package-level Backends.
+	Backends map[string]Backend
+}
+
+// Run captures all targets in m, and returns their statuses.
+func (c *Capturer) Run(ctx context.Context, m *Manifest) []Status {
+	parallel := c.Parallel
+	if parallel <= 0 {
+		parallel = 1
+	}
+	sem := make(chan struct{}, parallel)
+	statuses := make([]Status, len(m.Targets))
+	var wg sync.WaitGroup
+	wg.Add(len(m.Targets))
+	for i, t := range m.Targets {
+		go func(i int, t Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			statuses[i] = c.capture(ctx, m, t)
+		}(i, t)
+	}
+	wg.Wait()
+	return statuses
+}
+
+func (c *Capturer) backend(name string) Backend {
+	if b, ok := c.Backends[name]; ok {
+		return b
+	}
+	return Backends[name]
+}
+
+func (c *Capturer) capture(ctx context.Context, m *Manifest, t Target) Status {
+	start := time.Now()
+	s := Status{Name: t.Name}
+	logFile, err := os.Create(filepath.Join(c.OutDir, t.Name+".log"))
+	if err != nil {
+		s.ExitStatus = -1
+		s.Error = err.Error()
+		return s
+	}
+	defer logFile.Close()
+	logger := log.New(logFile, "", log.LstdFlags|log.Lmicroseconds)
+
+	s.Files, err = c.captureTo(ctx, m, t, logFile, logger)
+	s.Duration = time.Since(start).String()
+	if err != nil {
+		s.Error = err.Error()
+		s.ExitStatus = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			s.ExitStatus = exitErr.ExitCode()
+		}
+		logger.Printf("failed: %s", err)
+	} else {
+		logger.Printf("done in %s", s.Duration)
+	}
+	err = writeStatus(filepath.Join(c.OutDir, t.Name+".status"), s)
+	if err != nil {
+		logger.Printf("write status: %s", err)
+	}
+	return s
+}
+
+func writeStatus(path string, s Status) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func (c *Capturer) captureTo(ctx context.Context, m *Manifest, t Target, logFile io.Writer, logger *log.Logger) (files int, err error) {
+	req, err := request(m, t)
+	if err != nil {
+		return 0, err
+	}
+	inst, err := c.backend(t.Backend).Start(ctx, t.Image, logFile)
+	if inst != nil {
+		defer func() {
+			// clean up even if ctx is cancelled
+			ctx2, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			err2 := inst.Close(ctx2)
+			if err2 != nil {
+				logger.Printf("close: %s", err2)
+				if err == nil {
+					err = fmt.Errorf("close: %w", err2)
+				}
+			}
+		}()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("start: %w", err)
+	}
+
+	r := remote.New(inst.Transport())
+	r.Sudo = inst.Sudo()
+	r.BinDir = c.BinDir
+	r.Stderr = logFile
+	r.Logger = logger
+	r.Progress = func(p remote.Progress) {
+		files = p.Files
+		logger.Printf("progress: %s", p)
+	}
+	path, err := r.Install(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("install: %w", err)
+	}
+
+	outPath := filepath.Join(c.OutDir, t.Name+".hino")
+	tmp, err := os.CreateTemp(c.OutDir, t.Name+".hino.*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = r.Capture(ctx, path, req, tmp)
+	if err != nil {
+		tmp.Close()
+		return files, fmt.Errorf("capture: %w", err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return files, err
+	}
+	return files, os.Rename(tmp.Name(), outPath)
+}
+
+func request(m *Manifest, t Target) (*pb.StepHeader, error) {
+	w := wire.NewWalker()
+	packages := false
+	if t.Profile != "" {
+		c := m.config
+		if c == nil {
+			c = config.Default()
+		}
+		p, err := c.Resolve(t.Profile)
+		if err != nil {
+			return nil, err
+		}
+		err = p.Apply(w)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", t.Profile, err)
+		}
+		packages = p.Packages
+	}
+	if t.HashAll {
+		w.HashAll(true)
+	}
+	paths, err := wire.CompilePaths(t.Block)
+	if err != nil {
+		return nil, fmt.Errorf("block paths: %w", err)
+	}
+	w.Block(paths)
+	paths, err = wire.CompilePaths(t.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash paths: %w", err)
+	}
+	w.Hash(paths)
+	h := w.Header(t.Root)
+	h.Packages = packages
+	return h, nil
+}
@@ -0,0 +1,169 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	write := func(s string) {
+		err := os.WriteFile(path, []byte(s), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"targets": [
+		{"image": "ghcr.io/x/y:tag", "backend": "docker"},
+		{"image": "library/ubuntu", "backend": "docker"},
+		{"name": "vm", "image": "22.04", "backend": "multipass", "root": "/srv"}
+	]}`)
+	m, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ghcr.io_x_y_tag", "library_ubuntu", "vm"}
+	for i, tg := range m.Targets {
+		if tg.Name != want[i] {
+			t.Errorf("target %d: name %q, want %q", i, tg.Name, want[i])
+		}
+	}
+	if m.Targets[0].Root != "/" || m.Targets[2].Root != "/srv" {
+		t.Errorf("roots %q %q", m.Targets[0].Root, m.Targets[2].Root)
+	}
+
+	for _, s := range []string{
+		`{"targets": [{"image": "a:1", "backend": "docker"}, {"image": "a_1", "backend": "docker"}]}`,
+		`{"targets": [{"name": "a", "image": "x", "backend": "docker"}, {"name": "a", "image": "y", "backend": "docker"}]}`,
+		`{"targets": [{"image": "x", "backend": "fake"}]}`,
+	} {
+		write(s)
+		_, err = LoadManifest(path)
+		if err == nil {
+			t.Errorf("%s: no error", s)
+		}
+	}
+}
+
+// TestRun captures a local tree through Fake, with failing targets among them.
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds make-wire")
+	}
+	binDir := t.TempDir()
+	out, err := exec.Command("go", "build", "-o", filepath.Join(binDir, "make-wire-"+runtime.GOOS+"-"+runtime.GOARCH), "github.com/nyiyui/opt/hinomori/cmd/make-wire").CombinedOutput()
+	if err != nil {
+		t.Fatalf("build make-wire: %s\n%s", err, out)
+	}
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	for _, name := range []string{"f", "g"} {
+		err = os.WriteFile(filepath.Join(root, name), []byte(name), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Manifest{Targets: []Target{
+		{Name: "a", Image: "a", Backend: "fake", Root: root, HashAll: true},
+		{Name: "b", Image: "b", Backend: "fake", Root: root},
+		{Name: "fail", Image: "fail", Backend: "fake", Root: root},
+		{Name: "c", Image: "c", Backend: "fake", Root: root},
+		{Name: "nostart", Image: "nostart", Backend: "fake", Root: root},
+	}}
+	f := &Fake{Delay: 50 * time.Millisecond}
+	outDir := t.TempDir()
+	c := &Capturer{
+		OutDir:   outDir,
+		Parallel: 2,
+		BinDir:   binDir,
+		Backends: map[string]Backend{"fake": f},
+	}
+	statuses := c.Run(context.Background(), m)
+
+	if f.Open() != 0 {
+		t.Errorf("%d instances not closed", f.Open())
+	}
+	if f.MaxOpen() > c.Parallel {
+		t.Errorf("%d instances open at once, want at most %d", f.MaxOpen(), c.Parallel)
+	}
+	if f.MaxOpen() < 2 {
+		t.Errorf("%d instances open at once, want captures in parallel", f.MaxOpen())
+	}
+	for i, s := range statuses {
+		tg := m.Targets[i]
+		if s.Name != tg.Name {
+			t.Errorf("status %d: name %s, want %s", i, s.Name, tg.Name)
+		}
+		failed := tg.Name == "fail" || tg.Name == "nostart"
+		if failed {
+			if s.ExitStatus == 0 || s.Error == "" {
+				t.Errorf("%s: status %+v, want failure", tg.Name, s)
+			}
+		} else {
+			if s.ExitStatus != 0 || s.Error != "" {
+				t.Errorf("%s: status %+v, want success", tg.Name, s)
+			}
+			if s.Files != 2 {
+				t.Errorf("%s: %d files, want 2", tg.Name, s.Files)
+			}
+		}
+
+		data, err := os.ReadFile(filepath.Join(outDir, tg.Name+".status"))
+		if err != nil {
+			t.Errorf("%s: %s", tg.Name, err)
+		} else {
+			var s2 Status
+			err = json.Unmarshal(data, &s2)
+			if err != nil {
+				t.Errorf("%s: status: %s", tg.Name, err)
+			} else if s2 != s {
+				t.Errorf("%s: status file %+v, want %+v", tg.Name, s2, s)
+			}
+		}
+		log, err := os.ReadFile(filepath.Join(outDir, tg.Name+".log"))
+		if err != nil {
+			t.Errorf("%s: %s", tg.Name, err)
+		} else if len(log) == 0 {
+			t.Errorf("%s: empty log", tg.Name)
+		}
+		_, err = os.Stat(filepath.Join(outDir, tg.Name+".hino"))
+		if failed != os.IsNotExist(err) {
+			t.Errorf("%s: stat output: %v", tg.Name, err)
+		}
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	want := []string{
+		"a.hino", "a.log", "a.status",
+		"b.hino", "b.log", "b.status",
+		"c.hino", "c.log", "c.status",
+		"fail.log", "fail.status",
+		"nostart.log", "nostart.status",
+	}
+	if len(names) != len(want) {
+		t.Fatalf("output %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("output %v, want %v", names, want)
+		}
+	}
+}
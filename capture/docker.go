@@ -0,0 +1,36 @@
+package capture
+
+import (
+	"context"
+	"io"
+)
+
+// Docker runs targets as Docker containers.
+type Docker struct{}
+
+func (Docker) Start(ctx context.Context, image string, log io.Writer) (Instance, error) {
+	id, err := run(ctx, log, "docker", "create", "-i", "-t", image)
+	if err != nil {
+		return nil, err
+	}
+	i := &dockerInstance{id: id, log: log}
+	_, err = run(ctx, log, "docker", "start", id)
+	if err != nil {
+		return i, err
+	}
+	return i, nil
+}
+
+type dockerInstance struct {
+	id  string
+	log io.Writer
+}
+
+func (i *dockerInstance) Transport() []string { return []string{"docker", "exec", "-i", i.id} }
+
+func (i *dockerInstance) Sudo() bool { return false }
+
+func (i *dockerInstance) Close(ctx context.Context) error {
+	_, err := run(ctx, i.log, "docker", "rm", "-f", i.id)
+	return err
+}
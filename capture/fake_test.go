@@ -0,0 +1,74 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Fake runs targets as local subprocesses, for testing.
+// The image "nostart" fails to start, and "fail" starts but cannot run commands; other images are ignored.
+type Fake struct {
+	// Delay is how long starting takes (while open), so that captures overlap.
+	Delay time.Duration
+
+	lock    sync.Mutex
+	started int
+	closed  int
+	maxOpen int
+}
+
+func (f *Fake) Start(ctx context.Context, image string, log io.Writer) (Instance, error) {
+	fmt.Fprintf(log, "fake: start %s\n", image)
+	if image == "nostart" {
+		return nil, errors.New("fake: no start")
+	}
+	f.lock.Lock()
+	f.started++
+	if f.started-f.closed > f.maxOpen {
+		f.maxOpen = f.started - f.closed
+	}
+	f.lock.Unlock()
+	time.Sleep(f.Delay)
+	return &fakeInstance{f: f, log: log, fail: image == "fail"}, nil
+}
+
+// Open returns the number of instances started but not closed.
+func (f *Fake) Open() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.started - f.closed
+}
+
+// MaxOpen returns the most instances open at once.
+func (f *Fake) MaxOpen() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.maxOpen
+}
+
+type fakeInstance struct {
+	f    *Fake
+	log  io.Writer
+	fail bool
+}
+
+func (i *fakeInstance) Transport() []string {
+	if i.fail {
+		return []string{"false"}
+	}
+	return nil
+}
+
+func (i *fakeInstance) Sudo() bool { return false }
+
+func (i *fakeInstance) Close(ctx context.Context) error {
+	i.f.lock.Lock()
+	defer i.f.lock.Unlock()
+	i.f.closed++
+	fmt.Fprintf(i.log, "fake: close\n")
+	return nil
+}
@@ -0,0 +1,44 @@
+package capture
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+)
+
+// Multipass runs targets as Multipass instances.
+type Multipass struct{}
+
+func (Multipass) Start(ctx context.Context, image string, log io.Writer) (Instance, error) {
+	var b [4]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return nil, err
+	}
+	name := "hinomori-" + hex.EncodeToString(b[:])
+	i := &multipassInstance{name: name, log: log}
+	_, err = run(ctx, log, "multipass", "launch", image, "-n", name)
+	if err != nil {
+		// launch may fail after creating the instance
+		return i, err
+	}
+	return i, nil
+}
+
+type multipassInstance struct {
+	name string
+	log  io.Writer
+}
+
+func (i *multipassInstance) Transport() []string {
+	return []string{"multipass", "exec", i.name, "--"}
+}
+
+func (i *multipassInstance) Sudo() bool { return true }
+
+func (i *multipassInstance) Close(ctx context.Context) error {
+	// delete --purge with a name only purges that instance
+	_, err := run(ctx, i.log, "multipass", "delete", "--purge", i.name)
+	return err
+}
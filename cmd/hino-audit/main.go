@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+	"gopkg.in/yaml.v3"
+)
+
+// Checks are the names of the checks, in the order they are reported.
+var Checks = []string{
+	"setuid",
+	"setgid",
+	"world-writable",
+	"no-sticky",
+	"group-writable",
+	"unknown-uid",
+	"unknown-gid",
+	"capability",
+}
+
+var defaultSystemPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/usr", "/etc", "/boot"}
+
+// Baseline configures the audit.
+type Baseline struct {
+	// Allow are gitignore-style rules (relative to the snapshot's root) of paths allowed to fail each check.
+	// Rules for "all" apply to every check.
+	Allow map[string][]string `yaml:"allow"`
+	// SystemPaths are where group-writable files are flagged.
+	SystemPaths []string `yaml:"systemPaths"`
+}
+
+// Finding is a file failing a check.
+type Finding struct {
+	Check  string `json:"check"`
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags setuid/setgid files, world-writable files and directories without the sticky bit,\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "group-writable files in system paths, files owned by unknown UIDs and GIDs, and files with capabilities (if xattrs were captured).\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Exits with 1 if anything is flagged.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Checks: %s\n", strings.Join(Checks, ", "))
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var baselinePath string
+	var passwdPath string
+	var groupPath string
+	var jsonOut bool
+	var writeBaseline bool
+	flag.StringVar(&baselinePath, "baseline", "", "YAML file with allowed paths per check, and system paths")
+	flag.StringVar(&passwdPath, "passwd", "", "passwd file with known UIDs (default: users in the snapshot's header)")
+	flag.StringVar(&groupPath, "group", "", "group file with known GIDs (default: groups in the snapshot's header)")
+	flag.BoolVar(&jsonOut, "json", false, "output a JSON object per finding (JSON Lines)")
+	flag.BoolVar(&writeBaseline, "write-baseline", false, "output a baseline allowing everything flagged, instead of the findings")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	baseline := Baseline{SystemPaths: defaultSystemPaths}
+	if baselinePath != "" {
+		data, err := os.ReadFile(baselinePath)
+		if err != nil {
+			log.Fatalf("baseline: %s", err)
+		}
+		err = yaml.Unmarshal(data, &baseline)
+		if err != nil {
+			log.Fatalf("baseline: %s", err)
+		}
+	}
+	allow := map[string]*rules.Set{}
+	for check, lines := range baseline.Allow {
+		if check != "all" && !isCheck(check) {
+			log.Fatalf("baseline: unknown check %s", check)
+		}
+		rs, err := rules.ParseLines(lines, fmt.Sprintf("%s (%s)", baselinePath, check))
+		if err != nil {
+			log.Fatalf("baseline: %s: %s", check, err)
+		}
+		allow[check] = rules.NewSet("/", rs)
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("open: %s", err)
+	}
+	defer f.Close()
+	names := &wire.Names{}
+	if passwdPath != "" {
+		names.Users, err = wire.ReadIDFile(passwdPath)
+		if err != nil {
+			log.Fatalf("passwd: %s", err)
+		}
+	}
+	if groupPath != "" {
+		names.Groups, err = wire.ReadIDFile(groupPath)
+		if err != nil {
+			log.Fatalf("group: %s", err)
+		}
+	}
+	var findings []Finding
+	root := "/"
+	xattrs := false
+	err = wire.DecodeFiles(bufio.NewReader(f), func(h *pb.StepHeader) error {
+		root = h.Root
+		for _, name := range h.Metadata {
+			if name == "xattrs" {
+				xattrs = true
+			}
+		}
+		if names.Users == nil {
+			names.Users = h.Users
+		}
+		if names.Groups == nil {
+			names.Groups = h.Groups
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		rel := wire.InRoot(root, filepath.Join(fi.Path, fi.Name))
+		for _, fd := range audit(&fi, rel, baseline.SystemPaths, names) {
+			isDir := fi.Mode.IsDir()
+			if allow[fd.Check].Matches(rel, isDir) || allow["all"].Matches(rel, isDir) {
+				continue
+			}
+			findings = append(findings, fd)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("decode: %s", err)
+	}
+	if !xattrs {
+		log.Printf("xattrs were not captured, so capabilities are not checked")
+	}
+	if len(names.Users) == 0 {
+		log.Printf("no users are known (e.g. the snapshot's root has no etc/passwd), so owners are not checked")
+	}
+	if len(names.Groups) == 0 {
+		log.Printf("no groups are known (e.g. the snapshot's root has no etc/group), so groups are not checked")
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return checkIndex(findings[i].Check) < checkIndex(findings[j].Check)
+	})
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if writeBaseline {
+		b := Baseline{Allow: map[string][]string{}, SystemPaths: baseline.SystemPaths}
+		for _, fd := range findings {
+			b.Allow[fd.Check] = append(b.Allow[fd.Check], escape(fd.Path))
+		}
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		err = enc.Encode(b)
+		if err != nil {
+			log.Fatalf("encode: %s", err)
+		}
+		return
+	}
+	for _, fd := range findings {
+		if jsonOut {
+			data, err := json.Marshal(fd)
+			if err != nil {
+				log.Fatalf("marshal: %s", err)
+			}
+			out.Write(append(data, '\n'))
+			continue
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\n", fd.Check, fd.Path, fd.Detail)
+	}
+	if len(findings) != 0 {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+// audit returns the checks fi (at rel, relative to the root) fails.
+// Owners and groups are not checked if names has no users and groups.
+func audit(fi *wire.FileInfo2, rel string, systemPaths []string, names *wire.Names) []Finding {
+	var fds []Finding
+	add := func(check, format string, a ...interface{}) {
+		fds = append(fds, Finding{Check: check, Path: rel, Detail: fmt.Sprintf(format, a...)})
+	}
+	mode := fmt.Sprintf("%04o", wire.UnixMode(fi.Mode))
+	if fi.Mode&fs.ModeSymlink != 0 {
+		// the permissions of symlinks are not used
+	} else if fi.Mode.IsDir() {
+		if fi.Mode&0o002 != 0 && fi.Mode&fs.ModeSticky == 0 {
+			add("no-sticky", "mode %s", mode)
+		}
+	} else {
+		if fi.Mode&fs.ModeSetuid != 0 {
+			add("setuid", "mode %s, owner %s", mode, names.UserID(fi.Owner))
+		}
+		if fi.Mode&fs.ModeSetgid != 0 {
+			add("setgid", "mode %s, group %s", mode, names.GroupID(fi.Group))
+		}
+		if fi.Mode&0o002 != 0 {
+			add("world-writable", "mode %s", mode)
+		}
+		if fi.Mode&0o020 != 0 && inPaths(rel, systemPaths) {
+			add("group-writable", "mode %s, group %s", mode, names.GroupID(fi.Group))
+		}
+	}
+	if _, ok := names.Users[fi.Owner]; len(names.Users) != 0 && !ok {
+		add("unknown-uid", "owner %d", fi.Owner)
+	}
+	if _, ok := names.Groups[fi.Group]; len(names.Groups) != 0 && !ok {
+		add("unknown-gid", "group %d", fi.Group)
+	}
+	if _, ok := fi.Xattrs["security.capability"]; ok {
+		add("capability", "security.capability set")
+	}
+	return fds
+}
+
+func inPaths(rel string, paths []string) bool {
+	for _, p := range paths {
+		if rel == p || strings.HasPrefix(rel, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isCheck(name string) bool { return checkIndex(name) != -1 }
+
+func checkIndex(name string) int {
+	for i, check := range Checks {
+		if check == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// escape escapes path as a rule matching only path.
+func escape(path string) string {
+	b := new(strings.Builder)
+	for _, c := range path {
+		if strings.ContainsRune(`*?[\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/nyiyui/opt/hinomori/capture"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [manifest.json]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	var outDir string
+	var binDir string
+	var parallel int
+	flag.StringVar(&outDir, "out", ".", "directory to write <name>.hino, <name>.log, and <name>.status to")
+	flag.StringVar(&binDir, "bin-dir", ".", "directory containing make-wire-<goos>-<goarch> binaries")
+	flag.IntVar(&parallel, "j", 4, "maximum number of concurrent captures")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := capture.LoadManifest(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("manifest: %s", err)
+	}
+	err = os.MkdirAll(outDir, 0755)
+	if err != nil {
+		log.Fatalf("out: %s", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	c := &capture.Capturer{
+		OutDir:   outDir,
+		Parallel: parallel,
+		BinDir:   binDir,
+	}
+	failed := 0
+	for _, s := range c.Run(ctx, m) {
+		if s.Error != "" {
+			failed++
+			log.Printf("%s: failed (exit status %d): %s", s.Name, s.ExitStatus, s.Error)
+		} else {
+			log.Printf("%s: %d files in %s", s.Name, s.Files, s.Duration)
+		}
+	}
+	if failed != 0 {
+		log.Printf("%d of %d targets failed", failed, len(m.Targets))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/snapdb"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "%s import [flags] [db.sqlite] [wire.hino...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "%s query [db.sqlite] [sql] (reads sql from stdin if not given)\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Snapshots are named after their files without .hino, unless -name is given.\n")
+	fmt.Fprintf(os.Stderr, "Schema:%s", snapdb.Schema)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "import":
+		importMain(os.Args[2:])
+	case "query":
+		queryMain(os.Args[2:])
+	case "-h", "-help", "--help":
+		usage()
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func importMain(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var name string
+	fs.StringVar(&name, "name", "", "name of the snapshot (only with one file)")
+	fs.Parse(args)
+	if fs.NArg() < 2 || (name != "" && fs.NArg() != 2) {
+		usage()
+		os.Exit(2)
+	}
+	db, err := snapdb.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("open: %s", err)
+	}
+	defer db.Close()
+	for _, path := range fs.Args()[1:] {
+		name := name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(path), ".hino")
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		n, err := db.Import(name, bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			log.Fatalf("import %s: %s", path, err)
+		}
+		log.Printf("imported %d files from %s as %s", n, path, name)
+	}
+}
+
+func queryMain(args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		usage()
+		os.Exit(2)
+	}
+	var query string
+	if len(args) == 2 {
+		query = args[1]
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("read: %s", err)
+		}
+		query = string(data)
+	}
+	db, err := snapdb.Open(args[0])
+	if err != nil {
+		log.Fatalf("open: %s", err)
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		log.Fatalf("query: %s", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		log.Fatalf("columns: %s", err)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	fmt.Fprintln(out, strings.Join(columns, "\t"))
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	row := make([]string, len(columns))
+	for rows.Next() {
+		err = rows.Scan(ptrs...)
+		if err != nil {
+			log.Fatalf("scan: %s", err)
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+				row[i] = "NULL"
+			case []byte:
+				// hashes and xattr values
+				row[i] = hex.EncodeToString(v)
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Fprintln(out, strings.Join(row, "\t"))
+	}
+	err = rows.Err()
+	if err != nil {
+		log.Fatalf("query: %s", err)
+	}
+}
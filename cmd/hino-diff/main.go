@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/textdiff"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// snapshot is a loaded snapshot, with files by path relative to its root.
+type snapshot struct {
+	Name          string
+	HashAlgorithm string
+	Names         *wire.Names
+	Files         map[string]*wire.FileInfo2
+}
+
+// state is the state of a path in a snapshot, compared to the reference.
+type state int
+
+const (
+	same state = iota
+	distinct
+	missing
+)
+
+func (s state) String() string {
+	switch s {
+	case same:
+		return "✓ same"
+	case distinct:
+		return "≠ distinct"
+	case missing:
+		return "✗ missing"
+	default:
+		return "?"
+	}
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [[name=]wire.hino...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "e.g. %s ubuntu:22.04=ubuntu.hino debian:12=debian.hino\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Compares snapshots path by path (relative to their roots), reporting for each path that is not the same in all\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "whether each snapshot has the same content as the first snapshot that has it, distinct content, or is missing it.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Snapshots are named after their files without .hino, unless named.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var all bool
+	var summary bool
+	var metadata bool
+	var byName bool
+	var content bool
+	flag.BoolVar(&all, "all", false, "also report paths that are the same in all snapshots")
+	flag.BoolVar(&summary, "summary", false, "only print the summary")
+	flag.BoolVar(&metadata, "metadata", false, "also compare mode, owner, and group, not just content")
+	flag.BoolVar(&byName, "by-name", false, "with -metadata, compare owners and groups by name (from the snapshots' headers) instead of by ID")
+	flag.BoolVar(&content, "content", true, "show unified diffs of embedded content (see make-wire -content-rules) that is distinct")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	snapshots := make([]*snapshot, flag.NArg())
+	paths := map[string]struct{}{}
+	for i, arg := range flag.Args() {
+		name, path, ok := strings.Cut(arg, "=")
+		if !ok {
+			path = arg
+			name = strings.TrimSuffix(filepath.Base(path), ".hino")
+		}
+		s, err := load(name, path)
+		if err != nil {
+			log.Fatalf("load %s: %s", path, err)
+		}
+		snapshots[i] = s
+		for path := range s.Files {
+			paths[path] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	sort.Strings(sorted)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	var common, identical int
+	unique := make([]int, len(snapshots))
+	states := make([]state, len(snapshots))
+	for _, path := range sorted {
+		var ref *snapshot
+		present := 0
+		allSame := true
+		for i, s := range snapshots {
+			f, ok := s.Files[path]
+			switch {
+			case !ok:
+				states[i] = missing
+				allSame = false
+				continue
+			case ref == nil:
+				ref = s
+				states[i] = same
+			case sameContent(ref, s, ref.Files[path], f, metadata, byName):
+				states[i] = same
+			default:
+				states[i] = distinct
+				allSame = false
+			}
+			present++
+		}
+		if present == len(snapshots) {
+			common++
+			if allSame {
+				identical++
+			}
+		}
+		if present == 1 {
+			for i, st := range states {
+				if st != missing {
+					unique[i]++
+				}
+			}
+		}
+		if summary || (allSame && !all) {
+			continue
+		}
+		fmt.Fprintf(out, "%s\t", path)
+		for i, st := range states {
+			if i != 0 {
+				out.WriteString(", ")
+			}
+			fmt.Fprintf(out, "%s %s", snapshots[i].Name, st)
+		}
+		out.WriteString("\n")
+		if content {
+			for i, st := range states {
+				if st != distinct {
+					continue
+				}
+				a, b := ref.Files[path], snapshots[i].Files[path]
+				if !a.Embedded || !b.Embedded {
+					continue
+				}
+				out.WriteString(textdiff.Unified(ref.Name+":"+path, snapshots[i].Name+":"+path, a.Content, b.Content, 3))
+			}
+		}
+	}
+	if !summary {
+		out.WriteString("\n")
+	}
+	fmt.Fprintf(out, "%d paths, %d common to all (%d identical)\n", len(sorted), common, identical)
+	for i, s := range snapshots {
+		fmt.Fprintf(out, "%d only in %s\n", unique[i], s.Name)
+	}
+}
+
+// sameContent returns whether a (in sa) and b (in sb) have the same type and content.
+// Hashes are only compared if both have them with the same algorithm, otherwise sizes are.
+func sameContent(sa, sb *snapshot, a, b *wire.FileInfo2, metadata, byName bool) bool {
+	if a.Mode.Type() != b.Mode.Type() || a.Link != b.Link {
+		return false
+	}
+	if metadata {
+		if a.Mode != b.Mode {
+			return false
+		}
+		if byName {
+			if sa.Names.User(a.Owner) != sb.Names.User(b.Owner) || sa.Names.Group(a.Group) != sb.Names.Group(b.Group) {
+				return false
+			}
+		} else if a.Owner != b.Owner || a.Group != b.Group {
+			return false
+		}
+	}
+	if a.Mode.IsDir() {
+		// the size of a directory depends on the filesystem, not its contents
+		return true
+	}
+	if a.Size != b.Size {
+		return false
+	}
+	if len(a.Hash) != 0 && len(b.Hash) != 0 && sa.HashAlgorithm == sb.HashAlgorithm {
+		return bytes.Equal(a.Hash, b.Hash)
+	}
+	return true
+}
+
+func load(name, path string) (*snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := &snapshot{Name: name, HashAlgorithm: "xxhash", Files: map[string]*wire.FileInfo2{}}
+	root := "/"
+	err = wire.DecodeFiles(bufio.NewReader(f), func(h *pb.StepHeader) error {
+		root = h.Root
+		s.Names = wire.NamesFromHeader(h)
+		if h.HashAlgorithm != "" {
+			s.HashAlgorithm = h.HashAlgorithm
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		s.Files[wire.InRoot(root, filepath.Join(fi.Path, fi.Name))] = &fi
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// usage is the cumulative size and number of (non-directory) files in a directory.
+type usage struct {
+	Size  uint64
+	Count uint64
+}
+
+// row is a directory in the output.
+type row struct {
+	Path     string
+	Old, New usage
+}
+
+func (r row) growth() int64 { return int64(r.New.Size) - int64(r.Old.Size) }
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [old.hino] [new.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reports the cumulative (apparent) size and number of files of each directory (relative to the snapshot's root),\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "or with two snapshots, how directories grew from old to new.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var maxDepth int
+	var sortBy string
+	var top int
+	var human bool
+	flag.IntVar(&maxDepth, "depth", -1, "only report directories at most this deep (0 is the root; -1 for no limit)")
+	flag.StringVar(&sortBy, "sort", "", "sort by size, count, path, or growth (default: size, or growth with two snapshots)")
+	flag.IntVar(&top, "top", 0, "only report the first n directories (0 for all)")
+	flag.BoolVar(&human, "human", false, "print sizes in human-readable units (e.g. 1.5M)")
+	flag.Parse()
+	if flag.NArg() != 1 && flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	diff := flag.NArg() == 2
+	if sortBy == "" {
+		sortBy = "size"
+		if diff {
+			sortBy = "growth"
+		}
+	}
+
+	var old, cur map[string]usage
+	var err error
+	if diff {
+		old, err = load(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("load %s: %s", flag.Arg(0), err)
+		}
+		cur, err = load(flag.Arg(1))
+		if err != nil {
+			log.Fatalf("load %s: %s", flag.Arg(1), err)
+		}
+	} else {
+		cur, err = load(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("load %s: %s", flag.Arg(0), err)
+		}
+	}
+
+	rows := make([]row, 0, len(cur))
+	add := func(path string) {
+		if maxDepth >= 0 && depth(path) > maxDepth {
+			return
+		}
+		r := row{Path: path, Old: old[path], New: cur[path]}
+		if diff && r.Old == r.New {
+			return
+		}
+		rows = append(rows, r)
+	}
+	for path := range cur {
+		add(path)
+	}
+	for path := range old {
+		if _, ok := cur[path]; !ok {
+			add(path)
+		}
+	}
+
+	var less func(a, b row) bool
+	switch sortBy {
+	case "size":
+		less = func(a, b row) bool { return a.New.Size > b.New.Size }
+	case "count":
+		less = func(a, b row) bool { return a.New.Count > b.New.Count }
+	case "path":
+		less = func(a, b row) bool { return a.Path < b.Path }
+	case "growth":
+		less = func(a, b row) bool { return a.growth() > b.growth() }
+	default:
+		log.Fatalf("unknown sort %s", sortBy)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if less(rows[i], rows[j]) {
+			return true
+		}
+		if less(rows[j], rows[i]) {
+			return false
+		}
+		return rows[i].Path < rows[j].Path
+	})
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+
+	size := func(n uint64) string {
+		if human {
+			return humanSize(n)
+		}
+		return fmt.Sprint(n)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if diff {
+		fmt.Fprintf(out, "%12s %12s %12s %8s %s\n", "growth", "old", "new", "files", "path")
+		for _, r := range rows {
+			growth := r.growth()
+			sign := "+"
+			if growth < 0 {
+				sign = "-"
+				growth = -growth
+			}
+			files := int64(r.New.Count) - int64(r.Old.Count)
+			fmt.Fprintf(out, "%12s %12s %12s %+8d %s\n", sign+size(uint64(growth)), size(r.Old.Size), size(r.New.Size), files, r.Path)
+		}
+		return
+	}
+	fmt.Fprintf(out, "%12s %8s %s\n", "size", "files", "path")
+	for _, r := range rows {
+		fmt.Fprintf(out, "%12s %8d %s\n", size(r.New.Size), r.New.Count, r.Path)
+	}
+}
+
+// load aggregates the usage of each directory in the snapshot at path.
+func load(path string) (map[string]usage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	usages := map[string]usage{"/": {}}
+	root := "/"
+	err = wire.DecodeFiles(bufio.NewReader(f), func(h *pb.StepHeader) error {
+		root = h.Root
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		rel := wire.InRoot(root, filepath.Join(fi.Path, fi.Name))
+		if fi.Mode&fs.ModeDir != 0 {
+			if _, ok := usages[rel]; !ok {
+				usages[rel] = usage{}
+			}
+			return nil
+		}
+		for dir := filepath.Dir(rel); ; dir = filepath.Dir(dir) {
+			u := usages[dir]
+			u.Size += fi.Size
+			u.Count++
+			usages[dir] = u
+			if dir == "/" {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// depth returns how deep path (absolute, in the snapshot's root) is, with the root at 0.
+func depth(path string) int {
+	if path == "/" {
+		return 0
+	}
+	return strings.Count(path, "/")
+}
+
+// humanSize returns n in binary units (e.g. 1.5M), or in bytes if less than 1024.
+func humanSize(n uint64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprint(n)
+	}
+	f := float64(n)
+	i := -1
+	// 1023.95 would be printed as 1024.0 of the unit, rather than 1.0 of the next
+	for f >= 1023.95 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%c", f, units[i])
+}
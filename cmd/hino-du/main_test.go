@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDepth(t *testing.T) {
+	for path, want := range map[string]int{
+		"/":      0,
+		"/etc":   1,
+		"/etc/x": 2,
+		"/a/b/c": 3,
+	} {
+		got := depth(path)
+		if got != want {
+			t.Errorf("depth(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for _, c := range []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0"},
+		{1023, "1023"},
+		{1024, "1.0K"},
+		{1536, "1.5K"},
+		{1<<20 - 1<<10, "1023.0K"},
+		{1<<20 - 1, "1.0M"},
+		{1 << 20, "1.0M"},
+		{1 << 30, "1.0G"},
+		{1 << 40, "1.0T"},
+		{1 << 50, "1.0P"},
+		{1 << 60, "1.0E"},
+		// there is no unit past E
+		{math.MaxUint64, "16.0E"},
+	} {
+		got := humanSize(c.n)
+		if got != c.want {
+			t.Errorf("humanSize(%d) = %q, want %q", c.n, got, c.want)
+		}
+	}
+}
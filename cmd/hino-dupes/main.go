@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// key identifies candidate duplicates.
+type key struct {
+	Size          uint64
+	HashAlgorithm string
+	Hash          string
+}
+
+// file is a file in a snapshot.
+type file struct {
+	Snapshot string
+	// Path is where the file was captured (the snapshot's root joined with the path in it), to verify it.
+	Path string
+}
+
+type group struct {
+	key
+	Files []file
+}
+
+func (g group) wasted() uint64 { return g.Size * uint64(len(g.Files)-1) }
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [wire.hino...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reports groups of regular files with the same size and hash across snapshots, and the bytes wasted by each group.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Files without hashes (e.g. captured without -hash-all) are ignored.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var minSize uint64
+	var top int
+	var verify string
+	flag.Uint64Var(&minSize, "min-size", 1, "ignore files smaller than this")
+	flag.IntVar(&top, "top", 0, "only report the n groups wasting the most bytes (0 for all)")
+	flag.StringVar(&verify, "verify", "", fmt.Sprintf("rehash candidates in the original tree with this algorithm (from %s), e.g. sha256", wire.HashAlgorithmNames()))
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if _, ok := wire.HashAlgorithms[verify]; verify != "" && !ok {
+		log.Fatalf("unknown hash algorithm %s", verify)
+	}
+
+	candidates := map[key][]file{}
+	for _, path := range flag.Args() {
+		err := load(path, minSize, candidates)
+		if err != nil {
+			log.Fatalf("load %s: %s", path, err)
+		}
+	}
+	if verify != "" {
+		var err error
+		candidates, err = verifyCandidates(candidates, verify)
+		if err != nil {
+			log.Fatalf("verify: %s", err)
+		}
+	}
+	groups := make([]group, 0)
+	for k, files := range candidates {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].Snapshot != files[j].Snapshot {
+				return files[i].Snapshot < files[j].Snapshot
+			}
+			return files[i].Path < files[j].Path
+		})
+		groups = append(groups, group{k, files})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].wasted() != groups[j].wasted() {
+			return groups[i].wasted() > groups[j].wasted()
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+	var total uint64
+	for _, g := range groups {
+		total += g.wasted()
+	}
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, g := range groups {
+		fmt.Fprintf(out, "%d wasted: %d files of %d bytes, %s %x\n", g.wasted(), len(g.Files), g.Size, g.HashAlgorithm, g.Hash)
+		for _, f := range g.Files {
+			if flag.NArg() > 1 {
+				fmt.Fprintf(out, "\t%s:%s\n", f.Snapshot, f.Path)
+			} else {
+				fmt.Fprintf(out, "\t%s\n", f.Path)
+			}
+		}
+	}
+	fmt.Fprintf(out, "%d wasted in total\n", total)
+}
+
+// load adds the regular files with hashes in the snapshot at path to candidates.
+func load(path string, minSize uint64, candidates map[key][]file) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hashAlgorithm := "xxhash"
+	root := "/"
+	return wire.DecodeFiles(bufio.NewReader(f), func(h *pb.StepHeader) error {
+		root = h.Root
+		if h.HashAlgorithm != "" {
+			hashAlgorithm = h.HashAlgorithm
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		if !fi.Mode.IsRegular() || len(fi.Hash) == 0 || fi.Size < minSize {
+			return nil
+		}
+		k := key{Size: fi.Size, HashAlgorithm: hashAlgorithm, Hash: string(fi.Hash)}
+		// decoded paths start from / even under a relative root
+		onDisk := filepath.Join(root, wire.InRoot(root, filepath.Join(fi.Path, fi.Name)))
+		candidates[k] = append(candidates[k], file{Snapshot: path, Path: onDisk})
+		return nil
+	})
+}
+
+// verifyCandidates rehashes the files in candidates with algorithm, regrouping them.
+// A file that cannot be hashed (e.g. as it was captured elsewhere) is an error, rather than silently not a duplicate.
+func verifyCandidates(candidates map[key][]file, algorithm string) (map[key][]file, error) {
+	verified := map[key][]file{}
+	hashes := map[string][]byte{}
+	for k, files := range candidates {
+		if len(files) < 2 {
+			continue
+		}
+		for _, f := range files {
+			hash, ok := hashes[f.Path]
+			if !ok {
+				var err error
+				hash, err = wire.HashFile(f.Path, algorithm)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", f.Snapshot, f.Path, err)
+				}
+				if hash == nil {
+					return nil, fmt.Errorf("%s: %s: not a regular file", f.Snapshot, f.Path)
+				}
+				hashes[f.Path] = hash
+			}
+			k2 := key{Size: k.Size, HashAlgorithm: algorithm, Hash: string(hash)}
+			verified[k2] = append(verified[k2], f)
+		}
+	}
+	return verified, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// TestLoadRelative checks that files under a relative root are loaded and verified at their on-disk paths.
+func TestLoadRelative(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	err = os.MkdirAll(filepath.Join("td", "a"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"td/f", "td/a/g"} {
+		err = os.WriteFile(name, []byte("same"), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := filepath.Join(dir, "td.hino")
+	f, err := os.Create(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := wire.NewWriter(f)
+	err = w.WriteHeader(&pb.StepHeader{Version: wire.HeaderVersion, Root: "td", HashAlgorithm: "sha256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range []wire.FileInfo2{
+		{Mode: 0o644, Size: 4, Name: "f", Path: "/td", Hash: []byte("h"), Mtime: time.Unix(0, 0)},
+		{Mode: 0o644, Size: 4, Name: "g", Path: "/td/a", Hash: []byte("h"), Mtime: time.Unix(0, 0)},
+	} {
+		err = w.Write(&fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := map[key][]file{}
+	err = load(snapshot, 1, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := candidates[key{Size: 4, HashAlgorithm: "sha256", Hash: "h"}]
+	if len(files) != 2 || files[0].Path != "td/f" || files[1].Path != "td/a/g" {
+		t.Fatalf("files %+v", files)
+	}
+	verified, err := verifyCandidates(candidates, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(verified) != 1 {
+		t.Errorf("verified %+v, want one group", verified)
+	}
+
+	err = os.Remove("td/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = verifyCandidates(candidates, "sha256")
+	if err == nil {
+		t.Error("missing file: no error")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/mtree"
+	"github.com/nyiyui/opt/hinomori/restore"
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [wire.hino] > [exported]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reads from stdin if no file is given.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var format string
+	var columns string
+	var storeDir string
+	var contents bool
+	flag.StringVar(&format, "format", "mtree", "output format (mtree, jsonl, csv, or tar)")
+	flag.StringVar(&columns, "columns", strings.Join(defaultColumns, ","), fmt.Sprintf("comma-separated columns for csv (from %s)", strings.Join(columnNames(), ",")))
+	flag.BoolVar(&contents, "contents", false, "include the content of regular files in tar (embedded or from -store)")
+	flag.StringVar(&storeDir, "store", "", "blob store with the content of files for tar")
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		defer f.Close()
+		in = f
+	} else if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	var err error
+	switch format {
+	case "mtree":
+		err = exportMtree(bufio.NewReader(in), out)
+	case "jsonl":
+		err = exportJSONL(bufio.NewReader(in), out)
+	case "csv":
+		err = exportCSV(bufio.NewReader(in), out, strings.Split(columns, ","))
+	case "tar":
+		err = exportTar(in, out, storeDir, contents)
+	default:
+		log.Fatalf("unknown format %s", format)
+	}
+	if err != nil {
+		log.Fatalf("export: %s", err)
+	}
+}
+
+func exportTar(r io.Reader, w io.Writer, storeDir string, contents bool) error {
+	t := restore.NewTar(w)
+	t.Contents = contents
+	if storeDir != "" {
+		s, err := store.Open(storeDir)
+		if err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+		t.Store = s
+	}
+	failed, err := t.WriteSnapshot(r)
+	if err != nil {
+		return err
+	}
+	if failed != 0 {
+		log.Printf("%d files skipped", failed)
+	}
+	return t.Close()
+}
+
+func exportMtree(r io.Reader, w io.Writer) error {
+	mw := mtree.NewWriter(w)
+	root := "/"
+	hashAlgorithm := "xxhash"
+	return wire.DecodeFiles(r, func(h *pb.StepHeader) error {
+		root = h.Root
+		if h.HashAlgorithm != "" {
+			hashAlgorithm = h.HashAlgorithm
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		e, err := wire.MtreeEntry(root, fi, hashAlgorithm)
+		if err != nil {
+			return err
+		}
+		return mw.Write(e)
+	})
+}
+
+// record is a FileInfo2 as exported to JSON Lines and CSV.
+// Its JSON keys are stable, and all keys are present in every record.
+type record struct {
+	Path  string `json:"path"`
+	Type  string `json:"type"`
+	Mode  string `json:"mode"`
+	Size  uint64 `json:"size"`
+	Owner uint32 `json:"uid"`
+	Group uint32 `json:"gid"`
+	// User and GroupName are the names of Owner and Group in the snapshot's header, or empty if unknown.
+	User      string `json:"user"`
+	GroupName string `json:"group"`
+	// Hash is in hex, and HashAlgorithm is from the header.
+	Hash          string `json:"hash"`
+	HashAlgorithm string `json:"hashAlgorithm"`
+	HashErr       string `json:"hashErr"`
+	// Mtime is nil if not collected.
+	Mtime *time.Time `json:"mtime"`
+	Link  string     `json:"link"`
+	// Xattrs values are in hex.
+	Xattrs         map[string]string `json:"xattrs"`
+	MountPoint     bool              `json:"mountPoint"`
+	FsType         string            `json:"fsType"`
+	Package        string            `json:"package"`
+	PackageVersion string            `json:"packageVersion"`
+	PackageManager string            `json:"packageManager"`
+	PackageStatus  string            `json:"packageStatus"`
+}
+
+func newRecord(fi wire.FileInfo2, hashAlgorithm string, names *wire.Names) record {
+	r := record{
+		Path:           filepath.Join(fi.Path, fi.Name),
+		Type:           wire.FileType(fi.Mode),
+		Mode:           fmt.Sprintf("%04o", wire.UnixMode(fi.Mode)),
+		Size:           fi.Size,
+		Owner:          fi.Owner,
+		Group:          fi.Group,
+		Hash:           hex.EncodeToString(fi.Hash),
+		HashErr:        fi.HashErr,
+		Link:           fi.Link,
+		Xattrs:         map[string]string{},
+		MountPoint:     fi.MountPoint,
+		FsType:         fi.FsType,
+		Package:        fi.Package,
+		PackageVersion: fi.PackageVersion,
+		PackageManager: fi.PackageManager,
+		PackageStatus:  wire.PackageStatusName(fi.PackageStatus),
+	}
+	if len(fi.Hash) != 0 {
+		r.HashAlgorithm = hashAlgorithm
+	}
+	if names != nil {
+		r.User = names.Users[fi.Owner]
+		r.GroupName = names.Groups[fi.Group]
+	}
+	if !fi.Mtime.IsZero() {
+		mtime := fi.Mtime.UTC()
+		r.Mtime = &mtime
+	}
+	for k, v := range fi.Xattrs {
+		r.Xattrs[k] = hex.EncodeToString(v)
+	}
+	return r
+}
+
+// decodeRecords calls fn for each file in r as a record.
+func decodeRecords(r io.Reader, fn func(record) error) error {
+	hashAlgorithm := "xxhash"
+	var names *wire.Names
+	return wire.DecodeFiles(r, func(h *pb.StepHeader) error {
+		if h.HashAlgorithm != "" {
+			hashAlgorithm = h.HashAlgorithm
+		}
+		names = wire.NamesFromHeader(h)
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		return fn(newRecord(fi, hashAlgorithm, names))
+	})
+}
+
+func exportJSONL(r io.Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return decodeRecords(r, func(rec record) error {
+		return enc.Encode(rec)
+	})
+}
+
+var defaultColumns = []string{"path", "type", "mode", "size", "uid", "gid", "hash", "hashErr"}
+
+// csvColumns are the columns for csv, named as the keys in JSON.
+var csvColumns = map[string]func(r record) string{
+	"path":          func(r record) string { return r.Path },
+	"type":          func(r record) string { return r.Type },
+	"mode":          func(r record) string { return r.Mode },
+	"size":          func(r record) string { return strconv.FormatUint(r.Size, 10) },
+	"uid":           func(r record) string { return strconv.FormatUint(uint64(r.Owner), 10) },
+	"gid":           func(r record) string { return strconv.FormatUint(uint64(r.Group), 10) },
+	"user":          func(r record) string { return r.User },
+	"group":         func(r record) string { return r.GroupName },
+	"hash":          func(r record) string { return r.Hash },
+	"hashAlgorithm": func(r record) string { return r.HashAlgorithm },
+	"hashErr":       func(r record) string { return r.HashErr },
+	"mtime": func(r record) string {
+		if r.Mtime == nil {
+			return ""
+		}
+		return r.Mtime.Format(time.RFC3339Nano)
+	},
+	"link":           func(r record) string { return r.Link },
+	"mountPoint":     func(r record) string { return strconv.FormatBool(r.MountPoint) },
+	"fsType":         func(r record) string { return r.FsType },
+	"package":        func(r record) string { return r.Package },
+	"packageVersion": func(r record) string { return r.PackageVersion },
+	"packageManager": func(r record) string { return r.PackageManager },
+	"packageStatus":  func(r record) string { return r.PackageStatus },
+}
+
+func columnNames() []string {
+	names := make([]string, 0, len(csvColumns))
+	for name := range csvColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func exportCSV(r io.Reader, w io.Writer, columns []string) error {
+	fns := make([]func(record) string, len(columns))
+	for i, column := range columns {
+		fn, ok := csvColumns[column]
+		if !ok {
+			return fmt.Errorf("unknown column %s", column)
+		}
+		fns[i] = fn
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	row := make([]string, len(columns))
+	err := decodeRecords(r, func(rec record) error {
+		for i, fn := range fns {
+			row[i] = fn(rec)
+		}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
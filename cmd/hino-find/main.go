@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/find"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [wire.hino] [expression]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "e.g. %s snapshot.hino -type f -perm /6000 ! -path '/usr/*'\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Prints paths (relative to the snapshot's root) of files matching expression. Reads from stdin if the file is -.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "Expressions are like find(1):\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -name, -iname, -path, -regex, -type, -perm, -size, -uid, -gid, -hashed, -hash, -hash-error, -true, -false\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  ( ), !, -not, -a, -and, -o, -or\n")
+	}
+	var print0 bool
+	var count bool
+	flag.BoolVar(&print0, "0", false, "separate paths with NUL instead of newline")
+	flag.BoolVar(&count, "count", false, "print the number of matching files instead of paths")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	expr, err := find.Parse(flag.Args()[1:])
+	if err != nil {
+		log.Fatalf("expression: %s", err)
+	}
+
+	var in io.Reader = os.Stdin
+	if flag.Arg(0) != "-" {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	sep := byte('\n')
+	if print0 {
+		sep = 0
+	}
+	root := "/"
+	n := 0
+	err = wire.DecodeFiles(bufio.NewReader(in), func(h *pb.StepHeader) error {
+		root = h.Root
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		f := find.File{FileInfo2: fi, RelPath: wire.InRoot(root, filepath.Join(fi.Path, fi.Name))}
+		if !expr.Match(&f) {
+			return nil
+		}
+		n++
+		if count {
+			return nil
+		}
+		out.WriteString(f.RelPath)
+		return out.WriteByte(sep)
+	})
+	if err != nil {
+		log.Fatalf("decode: %s", err)
+	}
+	if count {
+		fmt.Fprintln(out, n)
+	}
+}
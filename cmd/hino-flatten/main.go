@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [full.hino] [incremental.hino...] > [flattened.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Replays a chain of incremental snapshots (each made with make-wire -parent of the one before) onto a full snapshot.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := wire.NewChain()
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		err = c.Apply(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			log.Fatalf("apply %s: %s", path, err)
+		}
+	}
+
+	bw := bufio.NewWriter(os.Stdout)
+	out := wire.NewTrailerWriter(bw)
+	err := wire.EncodeFiles(out, c.Header, c.FileInfos())
+	if err != nil {
+		log.Fatalf("encode: %s", err)
+	}
+	err = out.WriteTrailer()
+	if err != nil {
+		log.Fatalf("trailer: %s", err)
+	}
+	err = bw.Flush()
+	if err != nil {
+		log.Fatalf("flush: %s", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] -store [dir] [wire.hino...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Removes blobs in the store not referenced by any of the given (retained) snapshots.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var storeDir string
+	var dryRun bool
+	var verify bool
+	var all bool
+	var grace time.Duration
+	flag.StringVar(&storeDir, "store", "", "blob store")
+	flag.BoolVar(&dryRun, "n", false, "only print what would be removed")
+	flag.BoolVar(&verify, "verify", false, "also check that retained blobs have their hash, removing those that do not")
+	flag.BoolVar(&all, "all", false, "remove all blobs if no snapshots are given")
+	flag.DurationVar(&grace, "grace", 24*time.Hour, "keep blobs and temporary files newer than this, as they may be from captures in progress")
+	flag.Parse()
+	if storeDir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if flag.NArg() == 0 && !all {
+		log.Fatalf("no snapshots given (use -all to remove all blobs)")
+	}
+	s, err := store.Open(storeDir)
+	if err != nil {
+		log.Fatalf("store: %s", err)
+	}
+
+	referenced := map[string]bool{}
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		err = wire.DecodeFiles(bufio.NewReader(f), nil, func(fi wire.FileInfo2) error {
+			if fi.StoreHash != nil {
+				referenced[string(fi.StoreHash)] = true
+			}
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			log.Fatalf("decode %s: %s", path, err)
+		}
+	}
+	if flag.NArg() == 0 {
+		log.Printf("no snapshots given, so all blobs (older than %s) are removed", grace)
+	}
+
+	var kept, recent, removed int
+	var keptSize, removedSize int64
+	remove := func(hash []byte, size int64) error {
+		removed++
+		removedSize += size
+		if dryRun {
+			fmt.Printf("would remove %x\n", hash)
+			return nil
+		}
+		return s.Remove(hash)
+	}
+	err = s.Walk(func(hash []byte, size int64) error {
+		if !referenced[string(hash)] {
+			// recent ones may be from a capture whose snapshot is not written yet
+			info, err := os.Stat(s.Path(hash))
+			if err == nil && time.Since(info.ModTime()) < grace {
+				recent++
+				return nil
+			}
+			return remove(hash, size)
+		}
+		if verify {
+			if err := s.Verify(hash); err != nil {
+				log.Printf("verify: %s", err)
+				return remove(hash, size)
+			}
+		}
+		kept++
+		keptSize += size
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("walk: %s", err)
+	}
+	temps, err := s.Temps()
+	if err != nil {
+		log.Fatalf("temps: %s", err)
+	}
+	for _, path := range temps {
+		// recent ones may be writes in progress
+		info, err := os.Stat(path)
+		if err != nil || time.Since(info.ModTime()) < grace {
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would remove %s\n", path)
+			continue
+		}
+		err = os.Remove(path)
+		if err != nil {
+			log.Printf("remove: %s", err)
+		}
+	}
+	log.Printf("kept %d blobs (%d bytes) and %d unreferenced recent blobs, removed %d blobs (%d bytes)", kept, keptSize, recent, removed, removedSize)
+}
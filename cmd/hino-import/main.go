@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/mtree"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [input] > [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reads from stdin if no file is given. Gzipped input (e.g. pacman's .MTREE) is decompressed.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var format string
+	var root string
+	var hashAlgorithm string
+	var excludes []string
+	flag.StringVar(&format, "format", "mtree", "input format (mtree)")
+	flag.StringVar(&root, "root", "/", "root the input's paths are relative to")
+	flag.StringVar(&hashAlgorithm, "hash-algorithm", "sha256", "which digest to use as the hash (md5, sha1, or sha256)")
+	flag.Func("exclude", "gitignore-style rule for paths to exclude (e.g. '/.*' for pacman's .PKGINFO), may be repeated", func(s string) error {
+		excludes = append(excludes, s)
+		return nil
+	})
+	flag.Parse()
+
+	var in io.Reader = os.Stdin
+	if flag.NArg() == 1 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		defer f.Close()
+		in = f
+	} else if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	br := bufio.NewReader(in)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			log.Fatalf("gzip: %s", err)
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	rs, err := rules.ParseLines(excludes, "-exclude")
+	if err != nil {
+		log.Fatalf("exclude: %s", err)
+	}
+	exclude := rules.NewSet(root, rs)
+
+	h := &pb.StepHeader{
+		Version:       wire.HeaderVersion,
+		Root:          root,
+		HashAlgorithm: hashAlgorithm,
+		Source:        format,
+	}
+	var files []wire.FileInfo2
+	switch format {
+	case "mtree":
+		files, err = importMtree(br, h, exclude)
+	default:
+		log.Fatalf("unknown format %s", format)
+	}
+	if err != nil {
+		log.Fatalf("import: %s", err)
+	}
+	log.Printf("imported %d files", len(files))
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	err = wire.EncodeFiles(out, h, files)
+	if err != nil {
+		log.Fatalf("encode: %s", err)
+	}
+}
+
+func importMtree(r io.Reader, h *pb.StepHeader, exclude *rules.Set) ([]wire.FileInfo2, error) {
+	entries, err := mtree.Read(r)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]wire.FileInfo2, 0, len(entries))
+	hasMtime := false
+	for _, e := range entries {
+		if e.Path == "." {
+			continue
+		}
+		fi, err := wire.FileInfo2FromMtree(h.Root, e, h.HashAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		if exclude.Matches(filepath.Join(fi.Path, fi.Name), fi.Mode.IsDir()) {
+			continue
+		}
+		hasMtime = hasMtime || !fi.Mtime.IsZero()
+		files = append(files, fi)
+	}
+	if hasMtime {
+		h.Metadata = append(h.Metadata, "mtime")
+	}
+	h.Metadata = append(h.Metadata, "links")
+	return files, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/nyiyui/opt/hinomori/config"
+	"github.com/nyiyui/opt/hinomori/remote"
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [transport...] > [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "e.g. %s -- ssh host\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "     %s -- docker exec -i container\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "     %s -- multipass exec instance --\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "     %s -- env (local subprocess)\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	var root string
+	var block string
+	var hashAll bool
+	var hash string
+	var binDir string
+	var bin string
+	var sudo bool
+	var shell bool
+	var configPath string
+	var profileName string
+	var packages bool
+	flag.StringVar(&root, "root", "/", "root of tree on the remote")
+	flag.StringVar(&block, "block", "[]", "paths to block in JSON")
+	flag.BoolVar(&hashAll, "hash-all", false, "hash all files")
+	flag.StringVar(&hash, "hash", "[]", "paths to hash in JSON")
+	flag.StringVar(&configPath, "config", "", "config file with profiles (default: built-in profiles)")
+	flag.StringVar(&profileName, "profile", "", "profile to use (e.g. container, vm, host)")
+	flag.BoolVar(&packages, "packages", false, "attribute files to packages using the package databases on the remote")
+	flag.StringVar(&binDir, "bin-dir", ".", "directory containing make-wire-<goos>-<goarch> binaries")
+	flag.StringVar(&bin, "bin", "", "make-wire binary to use regardless of the remote's platform")
+	flag.BoolVar(&sudo, "sudo", false, "run make-wire under sudo")
+	flag.BoolVar(&shell, "shell", false, "quote the command for a remote shell (default if transport is ssh)")
+	flag.Parse()
+
+	r := remote.New(flag.Args())
+	r.BinDir = binDir
+	r.Bin = bin
+	r.Sudo = sudo
+	r.Shell = r.Shell || shell
+	r.Progress = func(p remote.Progress) {
+		if p.Done {
+			log.Printf("done: %s", p)
+		} else {
+			log.Printf("progress: %s", p)
+		}
+	}
+
+	walker := wire.NewWalker()
+	if profileName != "" {
+		p, err := config.LoadProfile(configPath, profileName)
+		if err != nil {
+			log.Fatalf("profile: %s", err)
+		}
+		err = p.Apply(walker)
+		if err != nil {
+			log.Fatalf("profile %s: %s", profileName, err)
+		}
+		packages = packages || p.Packages
+	}
+	if hashAll {
+		walker.HashAll(true)
+	}
+	paths, err := wire.JSONPaths(hash)
+	if err != nil {
+		log.Fatalf("hash paths: %s", err)
+	}
+	walker.Hash(paths)
+	paths, err = wire.JSONPaths(block)
+	if err != nil {
+		log.Fatalf("block paths: %s", err)
+	}
+	walker.Block(paths)
+	req := walker.Header(root)
+	req.Packages = packages
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	path, err := r.Install(ctx)
+	if err != nil {
+		log.Fatalf("install: %s", err)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	h, err := r.Capture(ctx, path, req, out)
+	if err != nil {
+		log.Fatalf("capture: %s", err)
+	}
+	err = out.Flush()
+	if err != nil {
+		log.Fatalf("flush: %s", err)
+	}
+	log.Printf("captured %s on %s (%s/%s)", h.Root, h.Hostname, h.Goos, h.Goarch)
+}
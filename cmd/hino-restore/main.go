@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/restore"
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] -o [dir] [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] -tar [file] [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Recreates the tree in the snapshot into a directory or a tar stream, with content from the snapshot or a blob store.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "With -metadata-only, repairs the metadata of an existing tree at dir instead.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var storeDir string
+	var target string
+	var tarPath string
+	var metadataOnly bool
+	var times bool
+	var dryRun bool
+	var noOwners bool
+	flag.StringVar(&storeDir, "store", "", "blob store with the content of files")
+	flag.StringVar(&target, "o", "", "directory to restore into")
+	flag.StringVar(&tarPath, "tar", "", "write a tar stream to this file (- for stdout) instead")
+	flag.BoolVar(&metadataOnly, "metadata-only", false, "only repair modes, owners, and xattrs of existing files in the directory")
+	flag.BoolVar(&times, "times", false, "also repair mtimes with -metadata-only")
+	flag.BoolVar(&dryRun, "n", false, "only print what would be repaired with -metadata-only")
+	flag.BoolVar(&noOwners, "no-owners", os.Geteuid() != 0, "do not change owners and groups (default when not root)")
+	flag.Parse()
+	if flag.NArg() != 1 || (target == "") == (tarPath == "") || (metadataOnly && tarPath != "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var s *store.Store
+	if storeDir != "" {
+		var err error
+		s, err = store.Open(storeDir)
+		if err != nil {
+			log.Fatalf("store: %s", err)
+		}
+	}
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("open: %s", err)
+	}
+	defer f.Close()
+
+	failed := 0
+	if tarPath != "" {
+		failed, err = writeTar(f, tarPath, s)
+		if err != nil {
+			log.Fatalf("tar: %s", err)
+		}
+	} else {
+		failed, err = restoreTree(f, &restore.Restorer{
+			Target:       target,
+			Store:        s,
+			NoOwners:     noOwners,
+			MetadataOnly: metadataOnly,
+			Times:        times,
+			DryRun:       dryRun,
+			Logger:       log.New(os.Stdout, "", 0),
+		})
+		if err != nil {
+			log.Fatalf("restore: %s", err)
+		}
+	}
+	if failed != 0 {
+		log.Printf("%d files failed", failed)
+		os.Exit(1)
+	}
+}
+
+// writeTar writes the snapshot in r, with contents, as a tar stream to path (- for stdout).
+func writeTar(r io.Reader, path string, s *store.Store) (failed int, err error) {
+	var out io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	t := restore.NewTar(bw)
+	t.Store = s
+	t.Contents = true
+	failed, err = t.WriteSnapshot(r)
+	if err != nil {
+		return failed, err
+	}
+	err = t.Close()
+	if err != nil {
+		return failed, err
+	}
+	return failed, bw.Flush()
+}
+
+// restoreTree restores each file in the snapshot in r with rr.
+func restoreTree(r io.Reader, rr *restore.Restorer) (failed int, err error) {
+	if !rr.MetadataOnly {
+		rr.Logger = nil
+		err = os.MkdirAll(rr.Target, 0o755)
+		if err != nil {
+			return 0, err
+		}
+	}
+	root := "/"
+	err = wire.DecodeFiles(bufio.NewReader(r), func(h *pb.StepHeader) error {
+		root = h.Root
+		for _, name := range h.Metadata {
+			if name == "xattrs" {
+				rr.Xattrs = true
+			}
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		rel := wire.InRoot(root, filepath.Join(fi.Path, fi.Name))
+		if err := rr.File(rel, &fi); err != nil {
+			log.Printf("%s: %s", rel, err)
+			failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return failed, err
+	}
+	return failed, rr.Finish()
+}
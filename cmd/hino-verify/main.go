@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/nyiyui/opt/hinomori/textdiff"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Change is a drift of a single path.
+type Change struct {
+	Path        string            `json:"path"`
+	Change      string            `json:"change"`
+	Differences []wire.Difference `json:"differences,omitempty"`
+	// Diff is a unified diff of embedded content, if both have it.
+	Diff string `json:"diff,omitempty"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] [snapshot.hino] [root]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] -against [other.hino] [snapshot.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reports drift of the tree at root (default: the snapshot's root), or another snapshot, from the snapshot.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Exits with 1 if there is drift.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var quick bool
+	var jsonOut bool
+	var against string
+	var ignoreAdded bool
+	var byName bool
+	flag.BoolVar(&quick, "quick", false, "only check size and mtime (no hashing)")
+	flag.BoolVar(&jsonOut, "json", false, "output a JSON object per change (JSON Lines)")
+	flag.StringVar(&against, "against", "", "compare against another snapshot instead of the live tree")
+	flag.BoolVar(&ignoreAdded, "ignore-added", false, "do not report files not in the snapshot (e.g. for a package's manifest)")
+	flag.BoolVar(&byName, "by-name", false, "compare owners and groups by name (from the snapshots' headers) instead of by ID")
+	flag.Parse()
+	if flag.NArg() != 1 && !(flag.NArg() == 2 && against == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	h, baseline, err := load(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("load %s: %s", flag.Arg(0), err)
+	}
+	if h == nil && against == "" {
+		log.Fatalf("load %s: no header, so the rules to walk with are unknown", flag.Arg(0))
+	}
+
+	opts := wire.CompareOptions{Quick: quick, ByName: byName, OldNames: wire.NamesFromHeader(h)}
+	opts.Xattrs = hasXattrs(h)
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	drift := false
+	report := func(c Change) {
+		drift = true
+		if jsonOut {
+			data, err := json.Marshal(c)
+			if err != nil {
+				log.Fatalf("marshal: %s", err)
+			}
+			out.Write(append(data, '\n'))
+			return
+		}
+		fmt.Fprintf(out, "%s %s", c.Change, c.Path)
+		for _, d := range c.Differences {
+			fmt.Fprintf(out, "\n\t%s", d)
+		}
+		fmt.Fprintln(out)
+		out.WriteString(c.Diff)
+	}
+
+	var r io.Reader
+	if against != "" {
+		f, err := os.Open(against)
+		if err != nil {
+			log.Fatalf("open: %s", err)
+		}
+		defer f.Close()
+		r = f
+	} else {
+		root := h.Root
+		if flag.NArg() == 2 {
+			root = flag.Arg(1)
+		}
+		r, err = walk(h, root, quick)
+		if err != nil {
+			log.Fatalf("walk: %s", err)
+		}
+	}
+	root := "/"
+	err = wire.DecodeFiles(bufio.NewReader(r), func(h2 *pb.StepHeader) error {
+		root = h2.Root
+		opts.Xattrs = opts.Xattrs && hasXattrs(h2)
+		opts.NewNames = wire.NamesFromHeader(h2)
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		rel := wire.InRoot(root, filepath.Join(fi.Path, fi.Name))
+		old, ok := baseline[rel]
+		if !ok {
+			if !ignoreAdded {
+				report(Change{Path: rel, Change: "added"})
+			}
+			return nil
+		}
+		delete(baseline, rel)
+		ds := wire.Compare(&old, &fi, opts)
+		if len(ds) != 0 {
+			c := Change{Path: rel, Change: "modified", Differences: ds}
+			if old.Embedded && fi.Embedded {
+				c.Diff = textdiff.Unified("snapshot:"+rel, "current:"+rel, old.Content, fi.Content, 3)
+			}
+			report(c)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("compare: %s", err)
+	}
+	removed := make([]string, 0, len(baseline))
+	for rel := range baseline {
+		removed = append(removed, rel)
+	}
+	sort.Strings(removed)
+	for _, rel := range removed {
+		report(Change{Path: rel, Change: "removed"})
+	}
+	if drift {
+		out.Flush()
+		os.Exit(1)
+	}
+}
+
+// walk walks the tree at root with the rules in h, returning the steps.
+func walk(h *pb.StepHeader, root string, quick bool) (io.Reader, error) {
+	h2 := proto.Clone(h).(*pb.StepHeader)
+	h2.Packages = false
+	if quick {
+		h2.HashAll = false
+		h2.Hash = nil
+		h2.HashRules = nil
+	}
+	walker, err := wire.NewWalkerFromHeader(h2)
+	if err != nil {
+		return nil, err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		err := wire.WriteHeader(pw, walker.Header(root))
+		if err == nil {
+			err = walker.Walk2(root, pw)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+func hasXattrs(h *pb.StepHeader) bool {
+	if h == nil {
+		return false
+	}
+	for _, name := range h.Metadata {
+		if name == "xattrs" {
+			return true
+		}
+	}
+	return false
+}
+
+// load reads a snapshot into a map of paths relative to its root.
+func load(path string) (*pb.StepHeader, map[string]wire.FileInfo2, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	var h *pb.StepHeader
+	files := map[string]wire.FileInfo2{}
+	err = wire.DecodeFiles(bufio.NewReader(f), func(h2 *pb.StepHeader) error {
+		h = h2
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		root := "/"
+		if h != nil {
+			root = h.Root
+		}
+		files[wire.InRoot(root, filepath.Join(fi.Path, fi.Name))] = fi
+		return nil
+	})
+	return h, files, err
+}
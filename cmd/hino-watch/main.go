@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/nyiyui/opt/hinomori/config"
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/watch"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "%s [flags] > [wire.hino]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Walks root, then writes a step for each change to it (creations, writes, chmods, and removals) until interrupted.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	var root string
+	var configPath string
+	var profileName string
+	var hashAll bool
+	var blockRules string
+	var hashRules string
+	var contentRules string
+	var hinoignore bool
+	var storeDir string
+	flag.StringVar(&root, "root", "/", "root of tree")
+	flag.StringVar(&configPath, "config", "", "config file with profiles (default: built-in profiles)")
+	flag.StringVar(&profileName, "profile", "", "profile to use (e.g. container, vm, host)")
+	flag.BoolVar(&hashAll, "hash-all", false, "hash all files")
+	flag.StringVar(&blockRules, "block-rules", "", "file with gitignore-style rules for paths to block")
+	flag.StringVar(&hashRules, "hash-rules", "", "file with gitignore-style rules for paths to hash")
+	flag.StringVar(&contentRules, "content-rules", "", "file with gitignore-style rules for regular files whose content to embed")
+	flag.BoolVar(&hinoignore, "hinoignore", false, "read block rules from "+wire.HinoignoreName+" files")
+	flag.StringVar(&storeDir, "store", "", "blob store to copy the content of hashed files to")
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	walker := wire.NewWalker()
+	if profileName != "" {
+		p, err := config.LoadProfile(configPath, profileName)
+		if err != nil {
+			log.Fatalf("profile: %s", err)
+		}
+		err = p.Apply(walker)
+		if err != nil {
+			log.Fatalf("profile %s: %s", profileName, err)
+		}
+	}
+	if hashAll {
+		walker.HashAll(true)
+	}
+	for _, r := range []struct {
+		path string
+		set  func(rules.Rules)
+	}{{blockRules, walker.BlockRules}, {hashRules, walker.HashRules}, {contentRules, walker.ContentRules}} {
+		if r.path == "" {
+			continue
+		}
+		rs, err := rules.ReadFile(r.path)
+		if err != nil {
+			log.Fatalf("rules: %s", err)
+		}
+		r.set(rs)
+	}
+	if hinoignore {
+		walker.Hinoignore(true)
+	}
+	if storeDir != "" {
+		s, err := store.Open(storeDir)
+		if err != nil {
+			log.Fatalf("store: %s", err)
+		}
+		walker.Store(s)
+	}
+
+	// watch before walking, so that changes during the walk are not missed
+	w, err := watch.New(root, walker)
+	if err != nil {
+		log.Fatalf("watch: %s", err)
+	}
+	defer w.Close()
+	out := bufio.NewWriter(os.Stdout)
+	err = wire.WriteHeader(out, walker.Header(root))
+	if err != nil {
+		log.Fatalf("header: %s", err)
+	}
+	dir, err := walker.Walk2Dir(root, out)
+	if err != nil {
+		log.Fatalf("walk: %s", err)
+	}
+	err = out.Flush()
+	if err != nil {
+		log.Fatalf("flush: %s", err)
+	}
+	err = w.Run(out, dir)
+	if err != nil {
+		log.Fatalf("watch: %s", err)
+	}
+}
@@ -2,34 +2,23 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
+	"strings"
 
 	"github.com/pkg/profile"
 
+	"github.com/nyiyui/opt/hinomori/config"
+	"github.com/nyiyui/opt/hinomori/pkgdb"
+	"github.com/nyiyui/opt/hinomori/restore"
+	"github.com/nyiyui/opt/hinomori/store"
 	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
 )
 
-func jsonPaths(s string) ([]*regexp.Regexp, error) {
-	paths := make([]string, 0)
-	err := json.Unmarshal([]byte(s), &paths)
-	if err != nil {
-		return nil, err
-	}
-	paths2 := make([]*regexp.Regexp, len(paths))
-	for i, path := range paths {
-		paths2[i], err = regexp.Compile(path)
-		if err != nil {
-			return nil, fmt.Errorf("path %d: %w", i, err)
-		}
-	}
-	return paths2, nil
-}
-
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
@@ -38,38 +27,237 @@ func main() {
 	var hashAll bool
 	var hash string
 	var prof bool
+	var serve bool
+	var configPath string
+	var profileName string
+	var blockRules string
+	var hashRules string
+	var hinoignore bool
+	var explain string
+	var oneFileSystem bool
+	var skipFsTypes string
+	var packages bool
+	var contentRules string
+	var maxContentSize uint64
+	var storeDir string
+	var tarOut bool
+	var contents bool
+	var parent string
 	flag.StringVar(&root, "root", "/", "root of tree")
 	flag.StringVar(&block, "block", "[]", "paths to block in JSON")
 	flag.BoolVar(&hashAll, "hash-all", false, "hash all files")
 	flag.StringVar(&hash, "hash", "[]", "paths to hash in JSON")
 	flag.BoolVar(&prof, "prof", false, "enable profiling")
+	flag.StringVar(&configPath, "config", "", "config file with profiles (default: built-in profiles)")
+	flag.StringVar(&profileName, "profile", "", "profile to use (e.g. container, vm, host)")
+	flag.StringVar(&blockRules, "block-rules", "", "file with gitignore-style rules for paths to block")
+	flag.StringVar(&hashRules, "hash-rules", "", "file with gitignore-style rules for paths to hash")
+	flag.BoolVar(&hinoignore, "hinoignore", false, "read block rules from "+wire.HinoignoreName+" files")
+	flag.BoolVar(&oneFileSystem, "one-file-system", false, "do not walk directories on other filesystems than the root")
+	flag.StringVar(&skipFsTypes, "skip-fs-types", "", "comma-separated filesystem types not to walk (e.g. sysfs,cgroup2,tmpfs,fuse.*)")
+	flag.BoolVar(&packages, "packages", false, "attribute files to packages using the package databases in the tree")
+	flag.StringVar(&contentRules, "content-rules", "", "file with gitignore-style rules for regular files whose content to embed")
+	flag.Uint64Var(&maxContentSize, "max-content-size", 0, fmt.Sprintf("size above which content is not embedded (default %d)", wire.DefaultMaxContentSize))
+	flag.StringVar(&storeDir, "store", "", "blob store to copy the content of hashed files to")
+	flag.BoolVar(&tarOut, "tar", false, "write a tar stream (e.g. for docker import) instead of wire format")
+	flag.BoolVar(&contents, "contents", false, "include the content of regular files with -tar")
+	flag.StringVar(&parent, "parent", "", "write an incremental snapshot to this snapshot (comma-separated: a full snapshot, then the incrementals to the parent in order)")
+	flag.StringVar(&explain, "explain", "", "explain which rules block or hash a path, instead of walking")
+	flag.BoolVar(&serve, "serve", false, "read options as a header from stdin (used by hino-remote)")
 	flag.Parse()
 
 	if prof {
 		defer profile.Start(profile.ProfilePath(".")).Stop()
 	}
 
-	walker := wire.NewWalker()
-	walker.HashAll(hashAll)
-	paths, err := jsonPaths(hash)
+	var walker *wire.Walker
+	if serve {
+		h, err := wire.ReadHeader(bufio.NewReader(os.Stdin))
+		if err != nil {
+			log.Fatalf("read request: %s", err)
+		}
+		walker, err = wire.NewWalkerFromHeader(h)
+		if err != nil {
+			log.Fatalf("request: %s", err)
+		}
+		root = h.Root
+	} else {
+		walker = wire.NewWalker()
+		if profileName != "" {
+			p, err := config.LoadProfile(configPath, profileName)
+			if err != nil {
+				log.Fatalf("profile: %s", err)
+			}
+			err = p.Apply(walker)
+			if err != nil {
+				log.Fatalf("profile %s: %s", profileName, err)
+			}
+			packages = packages || p.Packages
+		}
+		if hashAll {
+			walker.HashAll(true)
+		}
+		paths, err := wire.JSONPaths(hash)
+		if err != nil {
+			log.Fatalf("hash paths: %s", err)
+		}
+		walker.Hash(paths)
+		paths, err = wire.JSONPaths(block)
+		if err != nil {
+			log.Fatalf("block paths: %s", err)
+		}
+		walker.Block(paths)
+		if blockRules != "" {
+			rs, err := rules.ReadFile(blockRules)
+			if err != nil {
+				log.Fatalf("block rules: %s", err)
+			}
+			walker.BlockRules(rs)
+		}
+		if hashRules != "" {
+			rs, err := rules.ReadFile(hashRules)
+			if err != nil {
+				log.Fatalf("hash rules: %s", err)
+			}
+			walker.HashRules(rs)
+		}
+		if contentRules != "" {
+			rs, err := rules.ReadFile(contentRules)
+			if err != nil {
+				log.Fatalf("content rules: %s", err)
+			}
+			walker.ContentRules(rs)
+		}
+		if maxContentSize != 0 {
+			walker.MaxContentSize(maxContentSize)
+		}
+		if hinoignore {
+			walker.Hinoignore(true)
+		}
+		if storeDir != "" {
+			s, err := store.Open(storeDir)
+			if err != nil {
+				log.Fatalf("store: %s", err)
+			}
+			walker.Store(s)
+		}
+		if oneFileSystem {
+			walker.OneFileSystem(true)
+		}
+		if skipFsTypes != "" {
+			walker.SkipFsTypes(strings.Split(skipFsTypes, ","))
+		}
+		if packages {
+			db, err := pkgdb.Load(root)
+			if err != nil {
+				log.Fatalf("packages: %s", err)
+			}
+			walker.Packages(db)
+		}
+	}
+
+	if explain != "" {
+		lines, err := walker.Explain(root, explain)
+		if err != nil {
+			log.Fatalf("explain: %s", err)
+		}
+		for _, line := range lines {
+			fmt.Printf("%s: %s\n", explain, line)
+		}
+		return
+	}
+
+	if tarOut {
+		err := walkTar(walker, root, contents)
+		if err != nil {
+			log.Fatalf("tar: %s", err)
+		}
+		return
+	}
+
+	bw := bufio.NewWriter(os.Stdout)
+	defer bw.Flush()
+	out := wire.NewTrailerWriter(bw)
+	if parent != "" {
+		err := walkIncremental(walker, root, strings.Split(parent, ","), out)
+		if err != nil {
+			log.Fatalf("incremental: %s", err)
+		}
+		return
+	}
+	err := wire.WriteHeader(out, walker.Header(root))
+	if err != nil {
+		log.Printf("writing header: %s", err)
+	}
+	err = walker.Walk2(root, out)
+	if err != nil {
+		log.Fatalf("walk: %s", err)
+	}
+	err = out.WriteTrailer()
 	if err != nil {
-		log.Fatalf("hash paths: %s", err)
+		log.Fatalf("trailer: %s", err)
 	}
-	walker.Hash(paths)
-	paths, err = jsonPaths(block)
+}
+
+// walkIncremental walks root and writes the changes from the chain of snapshots at paths to out.
+func walkIncremental(walker *wire.Walker, root string, paths []string, out *wire.TrailerWriter) error {
+	c := wire.NewChain()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = c.Apply(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		err := wire.WriteHeader(pw, walker.Header(root))
+		if err == nil {
+			err = walker.Walk2(root, pw)
+		}
+		pw.CloseWithError(err)
+	}()
+	h, changed, removed, err := c.Changes(pr)
 	if err != nil {
-		log.Fatalf("block paths: %s", err)
+		return err
 	}
-	walker.Block(paths)
+	log.Printf("%d changed or added, %d removed", len(changed), len(removed))
+	h.Parent = c.Checksum
+	err = wire.EncodeIncremental(out, h, changed, removed)
+	if err != nil {
+		return err
+	}
+	return out.WriteTrailer()
+}
 
+// walkTar walks root and writes it as a tar stream to stdout, reading contents from the files themselves.
+func walkTar(walker *wire.Walker, root string, contents bool) error {
+	pr, pw := io.Pipe()
+	go func() {
+		err := wire.WriteHeader(pw, walker.Header(root))
+		if err == nil {
+			err = walker.Walk2(root, pw)
+		}
+		pw.CloseWithError(err)
+	}()
 	out := bufio.NewWriter(os.Stdout)
-	_, err = fmt.Fprintf(out, wire.WireMagic)
+	t := restore.NewTar(out)
+	t.Contents = contents
+	t.Live = true
+	failed, err := t.WriteSnapshot(pr)
 	if err != nil {
-		log.Printf("writing magic: %s", err)
+		return err
 	}
-	defer out.Flush()
-	err = walker.Walk2(root, out)
+	if failed != 0 {
+		log.Printf("%d files skipped", failed)
+	}
+	err = t.Close()
 	if err != nil {
-		log.Fatalf("walk: %s", err)
+		return err
 	}
+	return out.Flush()
 }
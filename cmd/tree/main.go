@@ -20,37 +20,57 @@ func main() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
 	}
+	var packages bool
+	var numeric bool
+	flag.BoolVar(&packages, "packages", false, "show owning packages")
+	flag.BoolVar(&numeric, "numeric", false, "show UIDs and GIDs instead of names")
 	flag.Parse()
 
-	var count int
-	stepCh := make(chan *pb.Step)
-	fiCh := make(chan wire.FileInfo2)
-	errCh := make(chan error)
-	// DecodeSteps -[stepCh]> ConvertSteps -[fiCh]> fmt.Printf
-	//                                     -[errCh]> log.Printf
-	go func() {
-		wire.ConvertSteps(stepCh, fiCh, errCh)
-	}()
-	go func() {
-		for err := range errCh {
-			log.Printf("convert: %s", err)
+	r := wire.NewReader(bufio.NewReader(os.Stdin))
+	log.Printf("waiting for input...")
+	h, err := r.Header()
+	if err != nil {
+		log.Fatalf("read header: %s", err)
+	}
+	var names *wire.Names
+	if !numeric {
+		names = wire.NamesFromHeader(h)
+	}
+	if packages {
+		fmt.Printf("%11s %8s %8s %8s %16s %-8s %s %s\n", "mode", "size", "own", "grp", "hash", "status", "path", "package")
+	} else {
+		fmt.Printf("%11s %8s %8s %8s %16s %s\n", "mode", "size", "own", "grp", "hash", "path")
+	}
+	count := 0
+	for {
+		f, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
-	}()
-	go func() {
-		stdin := bufio.NewReader(os.Stdin)
-		err := wire.DecodeSteps(stdin, stepCh)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				log.Printf("read %d files", count)
-				return
-			}
-			log.Fatal(err)
+			log.Fatalf("read: %s", err)
+		}
+		own, grp := names.User(f.Owner), names.Group(f.Group)
+		if packages {
+			fmt.Printf("%11s %8d %8s %8s %16x %-8s %s %s\n", f.Mode, f.Size, own, grp, f.Hash, packageStatus(f.PackageStatus), filepath.Join(f.Path, f.Name), packageName(*f))
+		} else {
+			fmt.Printf("%11s %8d %8s %8s %16x %s\n", f.Mode, f.Size, own, grp, f.Hash, filepath.Join(f.Path, f.Name))
 		}
-	}()
-	log.Printf("waiting for input...")
-	fmt.Printf("%11s %8s %6s %6s %16s %s\n", "mode", "size", "own", "grp", "hash", "path")
-	for f := range fiCh {
-		fmt.Printf("%11s %8d %6d %6d %16x %s\n", f.Mode, f.Size, f.Owner, f.Group, f.Hash, filepath.Join(f.Path, f.Name))
 		count++
 	}
+	log.Printf("read %d files", count)
+}
+
+func packageStatus(s pb.PackageStatus) string {
+	if name := wire.PackageStatusName(s); name != "" {
+		return name
+	}
+	return "-"
+}
+
+func packageName(f wire.FileInfo2) string {
+	if f.Package == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s=%s", f.PackageManager, f.Package, f.PackageVersion)
 }
@@ -0,0 +1,237 @@
+// Package config loads capture profiles from a YAML file.
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+//go:embed default.yaml
+var defaultConfig []byte
+
+// Config is a set of named profiles.
+type Config struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// Profile is how to capture a tree. Unset fields are inherited.
+type Profile struct {
+	// Inherits lists profiles to inherit from, in order. Later profiles override earlier ones.
+	Inherits      []string `yaml:"inherits"`
+	Block         []string `yaml:"block"`
+	Hash          []string `yaml:"hash"`
+	HashAll       *bool    `yaml:"hashAll"`
+	HashAlgorithm string   `yaml:"hashAlgorithm"`
+	Metadata      []string `yaml:"metadata"`
+	Limits        Limits   `yaml:"limits"`
+	// BlockRules and HashRules are gitignore-style rules relative to the root.
+	BlockRules []string `yaml:"blockRules"`
+	HashRules  []string `yaml:"hashRules"`
+	Hinoignore *bool    `yaml:"hinoignore"`
+	// OneFileSystem is whether to not walk directories on other filesystems than the root.
+	OneFileSystem *bool `yaml:"oneFileSystem"`
+	// SkipFsTypes are filesystem types (e.g. tmpfs, fuse.*) not to walk.
+	SkipFsTypes []string `yaml:"skipFsTypes"`
+	// Packages is whether to attribute files to packages using the package databases in the tree.
+	Packages *bool `yaml:"packages"`
+	// ContentRules are gitignore-style rules relative to the root of regular files whose content is embedded.
+	ContentRules []string `yaml:"contentRules"`
+}
+
+// Limits are limits of a Profile.
+type Limits struct {
+	MaxHashSize *uint64 `yaml:"maxHashSize"`
+	MaxDepth    *uint32 `yaml:"maxDepth"`
+	// MaxContentSize is the size above which content is not embedded.
+	MaxContentSize *uint64 `yaml:"maxContentSize"`
+}
+
+// Resolved is a Profile with inheritance resolved.
+type Resolved struct {
+	Name          string
+	Block         []string
+	Hash          []string
+	HashAll       bool
+	HashAlgorithm string
+	Metadata      []string
+	MaxHashSize   uint64
+	MaxDepth      uint32
+	BlockRules    []string
+	HashRules     []string
+	Hinoignore    bool
+	OneFileSystem bool
+	SkipFsTypes   []string
+	ContentRules  []string
+	// MaxContentSize is 0 for wire.DefaultMaxContentSize.
+	MaxContentSize uint64
+	// Packages is not applied by Apply, as loading package databases requires the root.
+	Packages bool
+}
+
+// Default returns the built-in profiles (container, vm, and host).
+func Default() *Config {
+	c, err := Parse(defaultConfig)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load reads a config from path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a config in YAML.
+func Parse(data []byte) (*Config, error) {
+	var c Config
+	err := yaml.Unmarshal(data, &c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Resolve returns the named profile with inheritance resolved.
+func (c *Config) Resolve(name string) (*Resolved, error) {
+	r := &Resolved{Name: name}
+	err := c.resolve(r, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (c *Config) resolve(r *Resolved, name string, visiting []string) error {
+	for _, name2 := range visiting {
+		if name == name2 {
+			return fmt.Errorf("profile %s: inheritance cycle %q", name, append(visiting, name))
+		}
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %s: not found", name)
+	}
+	visiting = append(visiting, name)
+	for _, parent := range p.Inherits {
+		err := c.resolve(r, parent, visiting)
+		if err != nil {
+			return err
+		}
+	}
+	r.Block = appendNew(r.Block, p.Block)
+	r.Hash = appendNew(r.Hash, p.Hash)
+	if p.HashAll != nil {
+		r.HashAll = *p.HashAll
+	}
+	if p.HashAlgorithm != "" {
+		r.HashAlgorithm = p.HashAlgorithm
+	}
+	r.Metadata = appendNew(r.Metadata, p.Metadata)
+	if p.Limits.MaxHashSize != nil {
+		r.MaxHashSize = *p.Limits.MaxHashSize
+	}
+	if p.Limits.MaxDepth != nil {
+		r.MaxDepth = *p.Limits.MaxDepth
+	}
+	if p.Limits.MaxContentSize != nil {
+		r.MaxContentSize = *p.Limits.MaxContentSize
+	}
+	// order matters, so duplicates are kept
+	r.BlockRules = append(r.BlockRules, p.BlockRules...)
+	r.HashRules = append(r.HashRules, p.HashRules...)
+	r.ContentRules = append(r.ContentRules, p.ContentRules...)
+	if p.Hinoignore != nil {
+		r.Hinoignore = *p.Hinoignore
+	}
+	if p.OneFileSystem != nil {
+		r.OneFileSystem = *p.OneFileSystem
+	}
+	r.SkipFsTypes = appendNew(r.SkipFsTypes, p.SkipFsTypes)
+	if p.Packages != nil {
+		r.Packages = *p.Packages
+	}
+	return nil
+}
+
+func appendNew(a, b []string) []string {
+outer:
+	for _, s := range b {
+		for _, s2 := range a {
+			if s == s2 {
+				continue outer
+			}
+		}
+		a = append(a, s)
+	}
+	return a
+}
+
+// Apply configures w to walk as described by r.
+func (r *Resolved) Apply(w *wire.Walker) error {
+	paths, err := wire.CompilePaths(r.Block)
+	if err != nil {
+		return fmt.Errorf("block: %w", err)
+	}
+	w.Block(paths)
+	paths, err = wire.CompilePaths(r.Hash)
+	if err != nil {
+		return fmt.Errorf("hash: %w", err)
+	}
+	w.Hash(paths)
+	w.HashAll(r.HashAll)
+	err = w.HashAlgorithm(r.HashAlgorithm)
+	if err != nil {
+		return err
+	}
+	err = w.Metadata(r.Metadata)
+	if err != nil {
+		return err
+	}
+	w.MaxHashSize(r.MaxHashSize)
+	w.MaxDepth(r.MaxDepth)
+	w.Profile(r.Name)
+	rs, err := rules.ParseLines(r.BlockRules, "profile "+r.Name+" blockRules")
+	if err != nil {
+		return fmt.Errorf("block rules: %w", err)
+	}
+	w.BlockRules(rs)
+	rs, err = rules.ParseLines(r.HashRules, "profile "+r.Name+" hashRules")
+	if err != nil {
+		return fmt.Errorf("hash rules: %w", err)
+	}
+	w.HashRules(rs)
+	rs, err = rules.ParseLines(r.ContentRules, "profile "+r.Name+" contentRules")
+	if err != nil {
+		return fmt.Errorf("content rules: %w", err)
+	}
+	w.ContentRules(rs)
+	w.MaxContentSize(r.MaxContentSize)
+	w.Hinoignore(r.Hinoignore)
+	w.OneFileSystem(r.OneFileSystem)
+	w.SkipFsTypes(r.SkipFsTypes)
+	return nil
+}
+
+// LoadProfile resolves the named profile from the config at path, or the default config if path is empty.
+func LoadProfile(path, name string) (*Resolved, error) {
+	c := Default()
+	if path != "" {
+		var err error
+		c, err = Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	}
+	return c.Resolve(name)
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+const testConfig = `
+profiles:
+  base:
+    block: ["^/a$"]
+    hash: ["^/h$"]
+    hashAll: true
+    hashAlgorithm: xxhash
+    metadata: [mtime]
+    blockRules: [/dev/]
+    skipFsTypes: [proc]
+    limits:
+      maxHashSize: 100
+      maxDepth: 3
+  left:
+    inherits: [base]
+    block: ["^/a$", "^/left$"]
+    hashAll: false
+    metadata: [links, mtime]
+    blockRules: [/dev/, /left/]
+    hinoignore: true
+  right:
+    inherits: [base]
+    hashAlgorithm: sha256
+    metadata: [xattrs]
+    blockRules: [/right/]
+    oneFileSystem: true
+    limits:
+      maxHashSize: 200
+  child:
+    inherits: [left, right]
+    block: ["^/child$"]
+    blockRules: ["!/dev/null"]
+    contentRules: ["*.conf"]
+    skipFsTypes: [tmpfs, proc]
+    packages: true
+    limits:
+      maxContentSize: 10
+  cycle1:
+    inherits: [cycle2]
+  cycle2:
+    inherits: [cycle1]
+  self:
+    inherits: [self]
+  orphan:
+    inherits: [base, missing]
+  deep:
+    inherits: [orphan]
+`
+
+func TestResolve(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		want Resolved
+	}{
+		{"base", Resolved{
+			Block:         []string{"^/a$"},
+			Hash:          []string{"^/h$"},
+			HashAll:       true,
+			HashAlgorithm: "xxhash",
+			Metadata:      []string{"mtime"},
+			MaxHashSize:   100,
+			MaxDepth:      3,
+			BlockRules:    []string{"/dev/"},
+			SkipFsTypes:   []string{"proc"},
+		}},
+		{"left", Resolved{
+			// sets are merged without duplicates, in order
+			Block:         []string{"^/a$", "^/left$"},
+			Hash:          []string{"^/h$"},
+			HashAll:       false,
+			HashAlgorithm: "xxhash",
+			Metadata:      []string{"mtime", "links"},
+			MaxHashSize:   100,
+			MaxDepth:      3,
+			// rules are appended, with duplicates
+			BlockRules:  []string{"/dev/", "/dev/", "/left/"},
+			SkipFsTypes: []string{"proc"},
+			Hinoignore:  true,
+		}},
+		{"child", Resolved{
+			// base is resolved again for right, after left
+			Block:          []string{"^/a$", "^/left$", "^/child$"},
+			Hash:           []string{"^/h$"},
+			HashAll:        true,
+			HashAlgorithm:  "sha256",
+			Metadata:       []string{"mtime", "links", "xattrs"},
+			MaxHashSize:    200,
+			MaxDepth:       3,
+			BlockRules:     []string{"/dev/", "/dev/", "/left/", "/dev/", "/right/", "!/dev/null"},
+			Hinoignore:     true,
+			OneFileSystem:  true,
+			SkipFsTypes:    []string{"proc", "tmpfs"},
+			ContentRules:   []string{"*.conf"},
+			MaxContentSize: 10,
+			Packages:       true,
+		}},
+	} {
+		r, err := c.Resolve(tc.name)
+		if err != nil {
+			t.Errorf("%s: %s", tc.name, err)
+			continue
+		}
+		tc.want.Name = tc.name
+		if !reflect.DeepEqual(*r, tc.want) {
+			t.Errorf("%s: resolved\n%+v, want\n%+v", tc.name, *r, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, err string
+	}{
+		{"cycle1", `profile cycle1: inheritance cycle ["cycle1" "cycle2" "cycle1"]`},
+		{"self", `profile self: inheritance cycle ["self" "self"]`},
+		{"orphan", "profile missing: not found"},
+		{"deep", "profile missing: not found"},
+		{"nonexistent", "profile nonexistent: not found"},
+	} {
+		_, err := c.Resolve(tc.name)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("%s: error %v, want %s", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	var names []string
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, " ") != "base container host vm" {
+		t.Errorf("profiles %q", names)
+	}
+	for _, name := range names {
+		r, err := c.Resolve(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		err = r.Apply(wire.NewWalker())
+		if err != nil {
+			t.Errorf("%s: apply: %s", name, err)
+		}
+		if !r.HashAll || !r.Packages || r.HashAlgorithm != "xxhash" {
+			t.Errorf("%s: not inheriting base: %+v", name, r)
+		}
+	}
+
+	r, err := c.Resolve("host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/dev/", "/proc/", "/sys/", "/run/", "/tmp/"}; !reflect.DeepEqual(r.BlockRules, want) {
+		t.Errorf("host: block rules %q, want %q", r.BlockRules, want)
+	}
+	if want := []string{"mtime", "links", "xattrs"}; !reflect.DeepEqual(r.Metadata, want) {
+		t.Errorf("host: metadata %q, want %q", r.Metadata, want)
+	}
+	if r.MaxHashSize != 1<<30 {
+		t.Errorf("host: max hash size %d", r.MaxHashSize)
+	}
+	r, err = c.Resolve("container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/dev/", "/proc/", "/sys/devices/"}; !reflect.DeepEqual(r.BlockRules, want) {
+		t.Errorf("container: block rules %q, want %q", r.BlockRules, want)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	r, err := LoadProfile("", "vm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "vm" {
+		t.Errorf("name %s", r.Name)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err = os.WriteFile(path, []byte(testConfig), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = LoadProfile(path, "right")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.HashAlgorithm != "sha256" || !r.OneFileSystem {
+		t.Errorf("right: %+v", r)
+	}
+	_, err = LoadProfile(path, "vm")
+	if err == nil {
+		t.Error("vm from a config without it: no error")
+	}
+	_, err = LoadProfile(filepath.Join(t.TempDir(), "missing.yaml"), "vm")
+	if err == nil {
+		t.Error("missing config: no error")
+	}
+
+	err = os.WriteFile(path, []byte("profiles: [\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadProfile(path, "vm")
+	if err == nil {
+		t.Error("invalid config: no error")
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, r := range []Resolved{
+		{Name: "block", Block: []string{"("}},
+		{Name: "hash", Hash: []string{"("}},
+		{Name: "algorithm", HashAlgorithm: "nonexistent"},
+		{Name: "metadata", Metadata: []string{"nonexistent"}},
+		{Name: "rules", BlockRules: []string{"[a"}},
+		{Name: "hash rules", HashRules: []string{"[a"}},
+		{Name: "content rules", ContentRules: []string{"[a"}},
+	} {
+		err := r.Apply(wire.NewWalker())
+		if err == nil {
+			t.Errorf("%s: no error", r.Name)
+		}
+	}
+}
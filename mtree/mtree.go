@@ -0,0 +1,217 @@
+// Package mtree reads mtree(5) specifications.
+package mtree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry is a single file in a specification.
+type Entry struct {
+	// Path is the path relative to the root, without a leading ./ (e.g. usr/bin/ls). The root is ".".
+	Path     string
+	Keywords map[string]string
+}
+
+// Get returns the keyword's value.
+func (e *Entry) Get(keyword string) (string, bool) {
+	v, ok := e.Keywords[keyword]
+	return v, ok
+}
+
+// Read reads a specification from r.
+// Both the full path format (paths containing /) and the hierarchical format (names, with .. to go up) are supported.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	set := map[string]string{}
+	cwd := "."
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	lineNum := 0
+	var cont string
+	for s.Scan() {
+		lineNum++
+		line := s.Text()
+		if strings.HasSuffix(line, `\`) {
+			cont += strings.TrimSuffix(line, `\`) + " "
+			continue
+		}
+		line = cont + line
+		cont = ""
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "/set":
+			for k, v := range parseKeywords(fields[1:]) {
+				set[k] = v
+			}
+			continue
+		case "/unset":
+			for _, k := range fields[1:] {
+				if k == "all" {
+					set = map[string]string{}
+				}
+				delete(set, k)
+			}
+			continue
+		case "..":
+			if cwd == "." {
+				return nil, fmt.Errorf("line %d: .. above root", lineNum)
+			}
+			cwd = path.Dir(cwd)
+			continue
+		}
+		name, err := Unescape(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		keywords := map[string]string{}
+		for k, v := range set {
+			keywords[k] = v
+		}
+		for k, v := range parseKeywords(fields[1:]) {
+			keywords[k] = v
+		}
+		var p string
+		if strings.Contains(name, "/") {
+			p = path.Clean(name)
+		} else {
+			p = path.Join(cwd, name)
+			if keywords["type"] == "dir" {
+				cwd = p
+			}
+		}
+		entries = append(entries, Entry{Path: p, Keywords: keywords})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func parseKeywords(fields []string) map[string]string {
+	keywords := map[string]string{}
+	for _, field := range fields {
+		k, v, _ := strings.Cut(field, "=")
+		if k == "link" {
+			if v2, err := Unescape(v); err == nil {
+				v = v2
+			}
+		}
+		keywords[k] = v
+	}
+	return keywords
+}
+
+// Unescape unescapes octal escapes such as \040 for a space, and backslash escapes.
+func Unescape(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	b := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+4 <= len(s) {
+			n, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			if err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("trailing backslash in %q", s)
+		}
+		i++
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+// Escape escapes s as in a path or link, using octal escapes for spaces, backslashes, and non-printable characters.
+func Escape(s string) string {
+	b := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '\\' || c == '#' || c == '=' {
+			fmt.Fprintf(b, `\%03o`, c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// keywordOrder is the order keywords are written in. Other keywords follow in alphabetical order.
+var keywordOrder = []string{"type", "mode", "uid", "gid", "uname", "gname", "size", "link", "time"}
+
+// Writer writes a specification in the full path format.
+type Writer struct {
+	w      io.Writer
+	header bool
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes e.
+func (w *Writer) Write(e Entry) error {
+	if !w.header {
+		_, err := io.WriteString(w.w, "#mtree\n")
+		if err != nil {
+			return err
+		}
+		w.header = true
+	}
+	b := new(strings.Builder)
+	p := e.Path
+	if p != "." {
+		p = "./" + p
+	}
+	b.WriteString(Escape(p))
+	seen := map[string]bool{}
+	write := func(k string) {
+		v, ok := e.Keywords[k]
+		if !ok || seen[k] {
+			return
+		}
+		seen[k] = true
+		if k == "link" {
+			v = Escape(v)
+		}
+		if v == "" {
+			fmt.Fprintf(b, " %s", k)
+		} else {
+			fmt.Fprintf(b, " %s=%s", k, v)
+		}
+	}
+	for _, k := range keywordOrder {
+		write(k)
+	}
+	rest := make([]string, 0, len(e.Keywords))
+	for k := range e.Keywords {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		write(k)
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
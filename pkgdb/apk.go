@@ -0,0 +1,58 @@
+package pkgdb
+
+import (
+	"encoding/base64"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const apkInstalled = "lib/apk/db/installed"
+
+func loadApk(db *DB, root string) error {
+	installed := filepath.Join(root, apkInstalled)
+	if !exists(installed) {
+		return errNotFound
+	}
+	var o Owner
+	var dir string
+	var lastFile string
+	return eachLine(installed, func(line string) error {
+		if line == "" {
+			o = Owner{}
+			dir = ""
+			lastFile = ""
+			return nil
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil
+		}
+		switch key {
+		case "P":
+			o = Owner{Manager: "apk", Name: value}
+		case "V":
+			o.Version = value
+		case "F":
+			dir = value
+			db.add(dir, o)
+		case "R":
+			lastFile = path.Join(dir, value)
+			db.add(lastFile, o)
+		case "Z":
+			// Q1 is base64-encoded SHA-1
+			if lastFile == "" || !strings.HasPrefix(value, "Q1") {
+				return nil
+			}
+			digest, err := base64.StdEncoding.DecodeString(value[2:])
+			if err != nil {
+				return nil
+			}
+			o2 := o
+			o2.Digest = digest
+			o2.DigestAlgorithm = "sha1"
+			db.add(lastFile, o2)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,111 @@
+package pkgdb
+
+import (
+	"bufio"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const dpkgDir = "var/lib/dpkg"
+
+func loadDpkg(db *DB, root string) error {
+	dir := filepath.Join(root, dpkgDir)
+	if !exists(filepath.Join(dir, "status")) {
+		return errNotFound
+	}
+	versions, err := readDpkgStatus(filepath.Join(dir, "status"))
+	if err != nil {
+		return err
+	}
+	lists, err := filepath.Glob(filepath.Join(dir, "info", "*.list"))
+	if err != nil {
+		return err
+	}
+	for _, list := range lists {
+		pkg := strings.TrimSuffix(filepath.Base(list), ".list")
+		// pkg may be qualified with an architecture (e.g. libc6:amd64)
+		name, _, _ := strings.Cut(pkg, ":")
+		o := Owner{Manager: "dpkg", Name: name, Version: versions[name]}
+		digests, err := readDpkgMd5sums(strings.TrimSuffix(list, ".list") + ".md5sums")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		err = eachLine(list, func(line string) error {
+			if line == "" || line == "/." {
+				return nil
+			}
+			o2 := o
+			if digest, ok := digests[line]; ok {
+				o2.Digest = digest
+				o2.DigestAlgorithm = "md5"
+			}
+			db.add(line, o2)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDpkgStatus returns the versions of installed packages.
+func readDpkgStatus(path string) (map[string]string, error) {
+	versions := map[string]string{}
+	var name, version string
+	err := eachLine(path, func(line string) error {
+		switch {
+		case line == "":
+			if name != "" {
+				versions[name] = version
+			}
+			name, version = "", ""
+		case strings.HasPrefix(line, "Package: "):
+			name = strings.TrimPrefix(line, "Package: ")
+		case strings.HasPrefix(line, "Version: "):
+			version = strings.TrimPrefix(line, "Version: ")
+		}
+		return nil
+	})
+	if name != "" {
+		versions[name] = version
+	}
+	return versions, err
+}
+
+// readDpkgMd5sums returns the digests in a md5sums file, keyed by absolute path.
+func readDpkgMd5sums(path string) (map[string][]byte, error) {
+	digests := map[string][]byte{}
+	err := eachLine(path, func(line string) error {
+		digest, p, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil
+		}
+		b, err := hex.DecodeString(digest)
+		if err != nil {
+			return nil
+		}
+		digests["/"+p] = b
+		return nil
+	})
+	return digests, err
+}
+
+func eachLine(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		err = fn(s.Text())
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
@@ -0,0 +1,109 @@
+package pkgdb
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/mtree"
+)
+
+const pacmanDir = "var/lib/pacman/local"
+
+func loadPacman(db *DB, root string) error {
+	dir := filepath.Join(root, pacmanDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return errNotFound
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		pkgDir := filepath.Join(dir, entry.Name())
+		desc, err := readPacmanSections(filepath.Join(pkgDir, "desc"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		o := Owner{Manager: "pacman"}
+		if v := desc["NAME"]; len(v) != 0 {
+			o.Name = v[0]
+		}
+		if v := desc["VERSION"]; len(v) != 0 {
+			o.Version = v[0]
+		}
+		files, err := readPacmanSections(filepath.Join(pkgDir, "files"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		digests, err := readPacmanMtree(filepath.Join(pkgDir, "mtree"))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		for _, p := range files["FILES"] {
+			p = strings.TrimSuffix(p, "/")
+			o2 := o
+			if digest, ok := digests[p]; ok {
+				o2.Digest = digest
+				o2.DigestAlgorithm = "sha256"
+			}
+			db.add(p, o2)
+		}
+	}
+	return nil
+}
+
+// readPacmanSections reads a file of %SECTION% headers followed by values, as in desc and files.
+func readPacmanSections(path string) (map[string][]string, error) {
+	sections := map[string][]string{}
+	var section string
+	err := eachLine(path, func(line string) error {
+		switch {
+		case line == "":
+			section = ""
+		case strings.HasPrefix(line, "%") && strings.HasSuffix(line, "%"):
+			section = strings.Trim(line, "%")
+		case section != "":
+			sections[section] = append(sections[section], line)
+		}
+		return nil
+	})
+	return sections, err
+}
+
+// readPacmanMtree returns the sha256 digests in a gzipped mtree file, keyed by path relative to the root.
+func readPacmanMtree(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	entries, err := mtree.Read(gz)
+	if err != nil {
+		return nil, err
+	}
+	digests := map[string][]byte{}
+	for _, e := range entries {
+		digest, ok := e.Get("sha256digest")
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+		digests[e.Path] = b
+	}
+	return digests, nil
+}
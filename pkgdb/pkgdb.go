@@ -0,0 +1,120 @@
+// Package pkgdb reads package databases (dpkg, pacman, apk, and rpm) inside a tree.
+package pkgdb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Owner is a package that owns a file.
+type Owner struct {
+	// Manager is the package manager, e.g. dpkg.
+	Manager string
+	Name    string
+	Version string
+	// Digest is the digest of the file recorded by the package manager, if any.
+	Digest []byte
+	// DigestAlgorithm is the algorithm of Digest, as in wire.HashAlgorithms.
+	DigestAlgorithm string
+}
+
+// DB maps paths (absolute, inside the tree) to their owners.
+type DB struct {
+	Managers []string
+	owners   map[string]Owner
+	// aliases maps symlinked directories (e.g. /bin) to their targets (e.g. /usr/bin).
+	aliases map[string]string
+}
+
+// Owner returns the owner of path, an absolute path inside the tree.
+func (db *DB) Owner(path string) (Owner, bool) {
+	o, ok := db.owners[path]
+	return o, ok
+}
+
+// Len returns the number of owned paths.
+func (db *DB) Len() int {
+	return len(db.owners)
+}
+
+func (db *DB) add(p string, o Owner) {
+	p = path.Clean("/" + p)
+	db.owners[p] = o
+	for from, to := range db.aliases {
+		if strings.HasPrefix(p, from+"/") {
+			p2 := to + strings.TrimPrefix(p, from)
+			if _, ok := db.owners[p2]; !ok {
+				db.owners[p2] = o
+			}
+		}
+	}
+}
+
+type loader struct {
+	name string
+	load func(db *DB, root string) error
+}
+
+var loaders = []loader{
+	{"dpkg", loadDpkg},
+	{"pacman", loadPacman},
+	{"apk", loadApk},
+	{"rpm", loadRpm},
+}
+
+// errNotFound is returned by a loader when its database does not exist in the tree.
+var errNotFound = errors.New("database not found")
+
+// Load reads all package databases found in the tree at root.
+func Load(root string) (*DB, error) {
+	db := &DB{
+		owners:  map[string]Owner{},
+		aliases: loadAliases(root),
+	}
+	for _, l := range loaders {
+		err := l.load(db, root)
+		if errors.Is(err, errNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.name, err)
+		}
+		db.Managers = append(db.Managers, l.name)
+	}
+	log.Printf("pkgdb: %d paths owned by %q", len(db.owners), db.Managers)
+	return db, nil
+}
+
+// loadAliases returns top-level directories that are symlinks to other directories in the tree (e.g. /bin to usr/bin on merged-/usr systems).
+func loadAliases(root string) map[string]string {
+	aliases := map[string]string{}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return aliases
+	}
+	for _, entry := range entries {
+		if entry.Type()&fs.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(root, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if !path.IsAbs(target) {
+			target = path.Join("/", target)
+		}
+		aliases["/"+entry.Name()] = path.Clean(target)
+	}
+	return aliases
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
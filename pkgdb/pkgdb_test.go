@@ -0,0 +1,91 @@
+package pkgdb
+
+import (
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+)
+
+type wantOwner struct {
+	path    string
+	name    string
+	version string
+	// digest is hex, with algorithm, or empty for none
+	digest, algorithm string
+}
+
+func TestLoad(t *testing.T) {
+	for _, c := range []struct {
+		manager string
+		len     int
+		owners  []wantOwner
+		// unowned are paths not owned
+		unowned []string
+	}{
+		{"dpkg", 7, []wantOwner{
+			{"/bin/ls", "coreutils", "9.1-1", "d41d8cd98f00b204e9800998ecf8427e", "md5"},
+			// through the /bin -> usr/bin alias
+			{"/usr/bin/ls", "coreutils", "9.1-1", "d41d8cd98f00b204e9800998ecf8427e", "md5"},
+			// the md5sums line is not hex
+			{"/usr/bin/cat", "coreutils", "9.1-1", "", ""},
+			{"/usr", "coreutils", "9.1-1", "", ""},
+			// the list is architecture-qualified
+			{"/usr/lib/x86_64-linux-gnu/libc.so.6", "libc6", "2.36-9+deb12u4", "", ""},
+		}, []string{"/", "/."}},
+		{"pacman", 5, []wantOwner{
+			{"/etc/bash.bashrc", "bash", "5.2.026-2", "0000000000000000000000000000000000000000000000000000000000000001", "sha256"},
+			{"/usr/bin", "bash", "5.2.026-2", "", ""},
+			// the mtree digest is not hex
+			{"/usr/bin/bash", "bash", "5.2.026-2", "", ""},
+		}, []string{"/.PKGINFO", "/var"}},
+		{"apk", 7, []wantOwner{
+			{"/lib", "musl", "1.2.4-r2", "", ""},
+			{"/lib/ld-musl-x86_64.so.1", "musl", "1.2.4-r2", "000102030405060708090a0b0c0d0e0f10111213", "sha1"},
+			{"/lib/libc.musl-x86_64.so.1", "musl", "1.2.4-r2", "", ""},
+			// Z: is not Q1, or not base64
+			{"/bin/busybox", "busybox", "1.36.1-r15", "", ""},
+			{"/etc/securetty", "busybox", "1.36.1-r15", "", ""},
+		}, nil},
+		{"rpm", 3, []wantOwner{
+			{"/usr/bin/bash", "bash", "1:5.2.15-3.fc39", "e1b3e8ef16e0b2c4a0f4e8a5c1e4b7d6f2a3c5e7d9b1a3c5e7f9a1b3c5d7e9f1", "sha256"},
+			{"/etc/bashrc", "bash", "1:5.2.15-3.fc39", "", ""},
+			// without a digest algorithm tag, digests are md5
+			{"/etc/passwd", "setup", "2.14.4-1.fc39", "0123456789abcdef0123456789abcdef", "md5"},
+		}, []string{"/usr/bin", "/etc"}},
+	} {
+		db, err := Load(filepath.Join("testdata", c.manager))
+		if err != nil {
+			t.Errorf("%s: %s", c.manager, err)
+			continue
+		}
+		if len(db.Managers) != 1 || db.Managers[0] != c.manager {
+			t.Errorf("%s: managers %q", c.manager, db.Managers)
+		}
+		if db.Len() != c.len {
+			t.Errorf("%s: %d paths owned, want %d", c.manager, db.Len(), c.len)
+		}
+		for _, w := range c.owners {
+			o, ok := db.Owner(w.path)
+			if !ok {
+				t.Errorf("%s: %s not owned", c.manager, w.path)
+				continue
+			}
+			if o.Manager != c.manager || o.Name != w.name || o.Version != w.version || hex.EncodeToString(o.Digest) != w.digest || o.DigestAlgorithm != w.algorithm {
+				t.Errorf("%s: %s: owner %+v, want %+v", c.manager, w.path, o, w)
+			}
+		}
+		for _, path := range c.unowned {
+			if o, ok := db.Owner(path); ok {
+				t.Errorf("%s: %s owned by %+v", c.manager, path, o)
+			}
+		}
+	}
+
+	db, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Managers) != 0 || db.Len() != 0 {
+		t.Errorf("empty tree: managers %q, %d paths", db.Managers, db.Len())
+	}
+}
@@ -0,0 +1,193 @@
+package pkgdb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	_ "modernc.org/sqlite"
+)
+
+const rpmSqlite = "var/lib/rpm/rpmdb.sqlite"
+
+// rpm header tags
+const (
+	rpmTagName           = 1000
+	rpmTagVersion        = 1001
+	rpmTagRelease        = 1002
+	rpmTagEpoch          = 1003
+	rpmTagFileDigests    = 1035
+	rpmTagDirIndexes     = 1116
+	rpmTagBasenames      = 1117
+	rpmTagDirnames       = 1118
+	rpmTagFileDigestAlgo = 5011
+)
+
+// rpm header types
+const (
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeStringArray = 8
+	rpmTypeI18nString  = 9
+)
+
+// rpmDigestAlgos maps PGPHASHALGO values to names in wire.HashAlgorithms.
+var rpmDigestAlgos = map[int32]string{
+	1: "md5",
+	2: "sha1",
+	8: "sha256",
+}
+
+func loadRpm(db *DB, root string) error {
+	dbPath := filepath.Join(root, rpmSqlite)
+	if !exists(dbPath) {
+		return errNotFound
+	}
+	sqlDB, err := sql.Open("sqlite", "file:"+dbPath+"?mode=ro&immutable=1")
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	rows, err := sqlDB.Query("SELECT blob FROM Packages")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var blob []byte
+		err = rows.Scan(&blob)
+		if err != nil {
+			return err
+		}
+		h, err := parseRpmHeader(blob)
+		if err != nil {
+			return err
+		}
+		err = h.addTo(db)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type rpmHeader struct {
+	ints    map[int32][]int32
+	strings map[int32][]string
+}
+
+// limits of a header, as in rpm's header.c
+const (
+	rpmMaxTags     = 0xffff
+	rpmMaxDataSize = 256 << 20
+)
+
+// parseRpmHeader parses a header as stored in rpmdb (without the header magic).
+// Malformed headers are errors, never out-of-range reads or huge allocations.
+func parseRpmHeader(blob []byte) (*rpmHeader, error) {
+	if len(blob) < 8 {
+		return nil, errors.New("header too short")
+	}
+	il := uint64(binary.BigEndian.Uint32(blob[0:]))
+	dl := uint64(binary.BigEndian.Uint32(blob[4:]))
+	if il > rpmMaxTags || dl > rpmMaxDataSize {
+		return nil, fmt.Errorf("header too large: %d tags, %d bytes of data", il, dl)
+	}
+	indexEnd := 8 + il*16
+	if uint64(len(blob)) < indexEnd+dl {
+		return nil, errors.New("header truncated")
+	}
+	data := blob[indexEnd : indexEnd+dl]
+	h := &rpmHeader{ints: map[int32][]int32{}, strings: map[int32][]string{}}
+	for i := uint64(0); i < il; i++ {
+		e := blob[8+i*16:]
+		tag := int32(binary.BigEndian.Uint32(e[0:]))
+		typ := binary.BigEndian.Uint32(e[4:])
+		offset := binary.BigEndian.Uint32(e[8:])
+		count := uint64(binary.BigEndian.Uint32(e[12:]))
+		if uint64(offset) > uint64(len(data)) {
+			return nil, fmt.Errorf("tag %d: offset out of range", tag)
+		}
+		d := data[offset:]
+		switch typ {
+		case rpmTypeInt32:
+			if uint64(len(d)) < count*4 {
+				return nil, fmt.Errorf("tag %d: truncated", tag)
+			}
+			values := make([]int32, count)
+			for j := range values {
+				values[j] = int32(binary.BigEndian.Uint32(d[j*4:]))
+			}
+			h.ints[tag] = values
+		case rpmTypeString, rpmTypeStringArray, rpmTypeI18nString:
+			if typ == rpmTypeString {
+				count = 1
+			}
+			// each string takes at least its terminator
+			if uint64(len(d)) < count {
+				return nil, fmt.Errorf("tag %d: truncated", tag)
+			}
+			values := make([]string, 0, count)
+			for j := uint64(0); j < count; j++ {
+				end := bytes.IndexByte(d, 0)
+				if end == -1 {
+					return nil, fmt.Errorf("tag %d: unterminated string", tag)
+				}
+				values = append(values, string(d[:end]))
+				d = d[end+1:]
+			}
+			h.strings[tag] = values
+		}
+	}
+	return h, nil
+}
+
+func (h *rpmHeader) string(tag int32) string {
+	if v := h.strings[tag]; len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (h *rpmHeader) addTo(db *DB) error {
+	o := Owner{
+		Manager: "rpm",
+		Name:    h.string(rpmTagName),
+		Version: h.string(rpmTagVersion) + "-" + h.string(rpmTagRelease),
+	}
+	if epoch := h.ints[rpmTagEpoch]; len(epoch) != 0 {
+		o.Version = strconv.Itoa(int(epoch[0])) + ":" + o.Version
+	}
+	algo := "md5"
+	if v := h.ints[rpmTagFileDigestAlgo]; len(v) != 0 {
+		algo = rpmDigestAlgos[v[0]]
+	}
+	basenames := h.strings[rpmTagBasenames]
+	dirnames := h.strings[rpmTagDirnames]
+	dirIndexes := h.ints[rpmTagDirIndexes]
+	digests := h.strings[rpmTagFileDigests]
+	if len(dirIndexes) != len(basenames) {
+		return fmt.Errorf("%s: %d dir indexes for %d basenames", o.Name, len(dirIndexes), len(basenames))
+	}
+	for i, basename := range basenames {
+		if int(dirIndexes[i]) >= len(dirnames) || dirIndexes[i] < 0 {
+			return fmt.Errorf("%s: dir index out of range", o.Name)
+		}
+		o2 := o
+		if i < len(digests) && digests[i] != "" && algo != "" {
+			digest, err := hex.DecodeString(digests[i])
+			if err == nil {
+				o2.Digest = digest
+				o2.DigestAlgorithm = algo
+			}
+		}
+		db.add(path.Join(dirnames[dirIndexes[i]], basename), o2)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package pkgdb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+// testRpmHeaders returns the headers in the fixture rpmdb.
+func testRpmHeaders(t *testing.T) [][]byte {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join("testdata", "rpm", rpmSqlite)+"?mode=ro&immutable=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	rows, err := db.Query("SELECT blob FROM Packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var blobs [][]byte
+	for rows.Next() {
+		var blob []byte
+		err = rows.Scan(&blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blobs = append(blobs, blob)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return blobs
+}
+
+// rpmIndex returns a header with the given index entries (tag, type, offset, count) and data.
+func rpmIndex(data []byte, entries ...[4]uint32) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, [2]uint32{uint32(len(entries)), uint32(len(data))})
+	for _, e := range entries {
+		binary.Write(&b, binary.BigEndian, e)
+	}
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestParseRpmHeader(t *testing.T) {
+	blobs := testRpmHeaders(t)
+	if len(blobs) == 0 {
+		t.Fatal("no headers")
+	}
+	for _, blob := range blobs {
+		h, err := parseRpmHeader(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.string(rpmTagName) == "" {
+			t.Errorf("no name in %+v", h)
+		}
+		// every truncation is an error, not a panic
+		for n := 0; n < len(blob); n++ {
+			_, err := parseRpmHeader(blob[:n])
+			if err == nil {
+				t.Errorf("%s truncated to %d bytes: no error", h.string(rpmTagName), n)
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		name string
+		blob []byte
+	}{
+		{"empty", nil},
+		{"huge index", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}},
+		{"huge data", []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}},
+		{"offset", rpmIndex([]byte("a\x00"), [4]uint32{rpmTagName, rpmTypeString, 3, 1})},
+		{"int32 count", rpmIndex(make([]byte, 8), [4]uint32{rpmTagEpoch, rpmTypeInt32, 0, 3})},
+		{"huge int32 count", rpmIndex(make([]byte, 8), [4]uint32{rpmTagEpoch, rpmTypeInt32, 4, 0xffffffff})},
+		{"huge string count", rpmIndex([]byte("a\x00"), [4]uint32{rpmTagBasenames, rpmTypeStringArray, 0, 0xffffffff})},
+		{"string count", rpmIndex([]byte("a\x00b\x00"), [4]uint32{rpmTagBasenames, rpmTypeStringArray, 0, 3})},
+		{"unterminated", rpmIndex([]byte("abc"), [4]uint32{rpmTagName, rpmTypeString, 0, 1})},
+	} {
+		_, err := parseRpmHeader(c.blob)
+		if err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+
+	h, err := parseRpmHeader(rpmIndex([]byte("a\x00\x00\x00\x00\x00\x00\x07"),
+		[4]uint32{rpmTagName, rpmTypeString, 0, 1},
+		[4]uint32{rpmTagEpoch, rpmTypeInt32, 4, 1},
+		// unknown types are skipped
+		[4]uint32{1, 7, 0, 100},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.string(rpmTagName) != "a" || len(h.ints[rpmTagEpoch]) != 1 || h.ints[rpmTagEpoch][0] != 7 {
+		t.Errorf("header %+v", h)
+	}
+}
+
+func TestRpmHeaderAddTo(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		h    *rpmHeader
+	}{
+		{"dir indexes", &rpmHeader{
+			ints:    map[int32][]int32{rpmTagDirIndexes: {0, 0}},
+			strings: map[int32][]string{rpmTagBasenames: {"a"}, rpmTagDirnames: {"/"}},
+		}},
+		{"dir index", &rpmHeader{
+			ints:    map[int32][]int32{rpmTagDirIndexes: {1}},
+			strings: map[int32][]string{rpmTagBasenames: {"a"}, rpmTagDirnames: {"/"}},
+		}},
+		{"negative dir index", &rpmHeader{
+			ints:    map[int32][]int32{rpmTagDirIndexes: {-1}},
+			strings: map[int32][]string{rpmTagBasenames: {"a"}, rpmTagDirnames: {"/"}},
+		}},
+	} {
+		err := c.h.addTo(&DB{owners: map[string]Owner{}})
+		if err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package remote runs make-wire on the other end of a stdio pipe, such as ssh, docker exec, or multipass exec.
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Remote is a controller for make-wire on the other end of Transport.
+type Remote struct {
+	// Transport is the command prefix to run a command on the remote, e.g. ["ssh", "host"].
+	// An empty Transport runs commands locally.
+	Transport []string
+	// Shell is whether Transport passes the command through a shell (e.g. ssh) and must be quoted.
+	Shell bool
+	// Sudo runs make-wire under sudo.
+	Sudo bool
+	// BinDir contains make-wire binaries named make-wire-<goos>-<goarch>.
+	BinDir string
+	// Bin overrides BinDir with a single binary.
+	Bin string
+	// Stderr receives the remote's standard error.
+	Stderr io.Writer
+	// Logger receives messages about the capture.
+	Logger *log.Logger
+	// Progress is called periodically while receiving steps.
+	Progress func(Progress)
+}
+
+// Progress is the progress of a capture.
+type Progress struct {
+	Steps int
+	Files int
+	Size  uint64
+	Bytes int64
+	Done  bool
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("%d steps %d files %d bytes in tree %d bytes received", p.Steps, p.Files, p.Size, p.Bytes)
+}
+
+// New returns a Remote for transport. If transport's command is ssh, Shell is set.
+func New(transport []string) *Remote {
+	r := &Remote{
+		Transport: transport,
+		Stderr:    os.Stderr,
+		Logger:    log.Default(),
+	}
+	if len(transport) != 0 && filepath.Base(transport[0]) == "ssh" {
+		r.Shell = true
+	}
+	return r
+}
+
+func (r *Remote) command(ctx context.Context, args ...string) *exec.Cmd {
+	if r.Shell {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = quote(arg)
+		}
+		args = []string{strings.Join(quoted, " ")}
+	}
+	args = append(append([]string{}, r.Transport...), args...)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = r.Stderr
+	return cmd
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Platform returns the GOOS and GOARCH of the remote.
+func (r *Remote) Platform(ctx context.Context) (goos, goarch string, err error) {
+	out, err := r.command(ctx, "uname", "-sm").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("uname: %w", err)
+	}
+	return parseUname(string(out))
+}
+
+func parseUname(s string) (goos, goarch string, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("unexpected uname output %q", s)
+	}
+	goos = strings.ToLower(fields[0])
+	switch fields[1] {
+	case "x86_64", "amd64":
+		goarch = "amd64"
+	case "aarch64", "arm64":
+		goarch = "arm64"
+	case "i386", "i686":
+		goarch = "386"
+	case "armv6l", "armv7l":
+		goarch = "arm"
+	case "riscv64", "ppc64le", "s390x":
+		goarch = fields[1]
+	default:
+		return "", "", fmt.Errorf("unknown machine %s", fields[1])
+	}
+	return goos, goarch, nil
+}
+
+// binPath returns the local make-wire binary for the given platform.
+func (r *Remote) binPath(goos, goarch string) string {
+	if r.Bin != "" {
+		return r.Bin
+	}
+	return filepath.Join(r.BinDir, fmt.Sprintf("make-wire-%s-%s", goos, goarch))
+}
+
+// Install uploads the make-wire binary for the remote's platform if it is not present, and returns its path on the remote.
+// Binaries are named by their digest, so a stale binary is never reused.
+// Concurrent installs to the same remote are safe.
+func (r *Remote) Install(ctx context.Context) (string, error) {
+	goos, goarch, err := r.Platform(ctx)
+	if err != nil {
+		return "", err
+	}
+	bin, err := os.ReadFile(r.binPath(goos, goarch))
+	if err != nil {
+		return "", fmt.Errorf("binary for %s/%s: %w", goos, goarch, err)
+	}
+	digest := sha256.Sum256(bin)
+	name := "make-wire-" + hex.EncodeToString(digest[:8])
+	script := `d="$HOME/.cache/hinomori"; f="$d/` + name + `"
+if test -x "$f"; then echo present; else
+	t="$f.tmp.$$"
+	mkdir -p "$d" && cat > "$t" && chmod 755 "$t" && mv "$t" "$f" && echo uploaded || { rm -f "$t"; exit 1; }
+fi
+echo "$f"`
+	cmd := r.command(ctx, "sh", "-c", script)
+	cmd.Stdin = bytes.NewReader(bin)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("install: %w", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 {
+		return "", fmt.Errorf("install: unexpected output %q", out)
+	}
+	r.Logger.Printf("make-wire for %s/%s %s at %s", goos, goarch, lines[0], lines[1])
+	return lines[1], nil
+}
+
+// Capture runs make-wire at path on the remote with the options in req, and writes its output to out.
+// The header the remote responded with is returned.
+func (r *Remote) Capture(ctx context.Context, path string, req *pb.StepHeader, out io.Writer) (*pb.StepHeader, error) {
+	args := []string{path, "-serve"}
+	if r.Sudo {
+		args = append([]string{"sudo"}, args...)
+	}
+	cmd := r.command(ctx, args...)
+	var reqBuf bytes.Buffer
+	err := wire.WriteHeader(&reqBuf, req)
+	if err != nil {
+		return nil, fmt.Errorf("request: %w", err)
+	}
+	cmd.Stdin = &reqBuf
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+	h, err := r.receive(bufio.NewReader(stdout), req, out)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	err = cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("make-wire: %w", err)
+	}
+	return h, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (r *Remote) receive(stdout io.Reader, req *pb.StepHeader, out io.Writer) (*pb.StepHeader, error) {
+	cw := &countingWriter{w: out}
+	tee := io.TeeReader(stdout, cw)
+	h, err := wire.ReadHeader(tee)
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	err = checkHeader(req, h)
+	if err != nil {
+		return nil, err
+	}
+	var p Progress
+	last := time.Now()
+	for {
+		step, err := wire.DecodeStep(tee)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("steps: %w", err)
+		}
+		p.Steps++
+		if f, ok := step.Step.(*pb.Step_File); ok {
+			p.Files++
+			p.Size += f.File.Size
+		}
+		if r.Progress != nil && time.Since(last) > time.Second {
+			p.Bytes = cw.n
+			r.Progress(p)
+			last = time.Now()
+		}
+	}
+	if r.Progress != nil {
+		p.Bytes = cw.n
+		p.Done = true
+		r.Progress(p)
+	}
+	return h, nil
+}
+
+// checkHeader checks that the remote agreed to walk as requested.
+func checkHeader(req, h *pb.StepHeader) error {
+	if h.Version != wire.HeaderVersion {
+		return fmt.Errorf("remote header version %d, want %d", h.Version, wire.HeaderVersion)
+	}
+	req2 := proto.Clone(req).(*pb.StepHeader)
+	h2 := proto.Clone(h).(*pb.StepHeader)
+	for _, h := range []*pb.StepHeader{req2, h2} {
+		// describes the remote, not how to walk
+		h.Hostname = ""
+		h.Goos = ""
+		h.Goarch = ""
+		h.PackageManagers = nil
+		h.Users = nil
+		h.Groups = nil
+	}
+	if !proto.Equal(req2, h2) {
+		return fmt.Errorf("remote header %v, want %v", h2, req2)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+func TestParseUname(t *testing.T) {
+	for _, c := range []struct {
+		in           string
+		goos, goarch string
+	}{
+		{"Linux x86_64\n", "linux", "amd64"},
+		{"Linux aarch64\n", "linux", "arm64"},
+		{"Darwin arm64\n", "darwin", "arm64"},
+		{"FreeBSD amd64\n", "freebsd", "amd64"},
+	} {
+		goos, goarch, err := parseUname(c.in)
+		if err != nil {
+			t.Errorf("%q: %s", c.in, err)
+			continue
+		}
+		if goos != c.goos || goarch != c.goarch {
+			t.Errorf("%q: got %s/%s, want %s/%s", c.in, goos, goarch, c.goos, c.goarch)
+		}
+	}
+	for _, in := range []string{"", "Linux", "Linux mips"} {
+		_, _, err := parseUname(in)
+		if err == nil {
+			t.Errorf("%q: no error", in)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	out, err := exec.Command("sh", "-c", "printf %s "+quote(`it's a "test" $HOME`)).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `it's a "test" $HOME` {
+		t.Errorf("got %q", out)
+	}
+}
+
+// buildMakeWire builds make-wire for the local platform.
+func buildMakeWire(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds make-wire")
+	}
+	bin := filepath.Join(t.TempDir(), "make-wire")
+	out, err := exec.Command("go", "build", "-o", bin, "github.com/nyiyui/opt/hinomori/cmd/make-wire").CombinedOutput()
+	if err != nil {
+		t.Fatalf("build make-wire: %s\n%s", err, out)
+	}
+	return bin
+}
+
+// TestLocal installs make-wire and captures a tree with a local subprocess standing in for ssh.
+func TestLocal(t *testing.T) {
+	bin := buildMakeWire(t)
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "a", "b"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"f", "a/g", "a/b/h"} {
+		err = os.WriteFile(filepath.Join(root, name), []byte(name), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, shell := range []bool{false, true} {
+		transport := []string{"env"}
+		if shell {
+			transport = []string{"sh", "-c"}
+		}
+		r := New(transport)
+		r.Shell = shell
+		r.Bin = bin
+		r.Stderr = io.Discard
+		r.Logger = log.New(io.Discard, "", 0)
+		ctx := context.Background()
+		path, err := r.Install(ctx)
+		if err != nil {
+			t.Fatalf("shell %t: install: %s", shell, err)
+		}
+		path2, err := r.Install(ctx)
+		if err != nil {
+			t.Fatalf("shell %t: install again: %s", shell, err)
+		}
+		if path != path2 {
+			t.Errorf("shell %t: installed to %s, then %s", shell, path, path2)
+		}
+
+		walker := wire.NewWalker()
+		walker.HashAll(true)
+		req := walker.Header(root)
+		var out bytes.Buffer
+		var progress Progress
+		r.Progress = func(p Progress) { progress = p }
+		h, err := r.Capture(ctx, path, req, &out)
+		if err != nil {
+			t.Fatalf("shell %t: capture: %s", shell, err)
+		}
+		if h.Root != root {
+			t.Errorf("shell %t: root %s, want %s", shell, h.Root, root)
+		}
+		var names []string
+		err = wire.DecodeFiles(&out, nil, func(fi wire.FileInfo2) error {
+			rel, err := filepath.Rel(root, filepath.Join(fi.Path, fi.Name))
+			if err != nil {
+				return err
+			}
+			if fi.Mode.IsRegular() && len(fi.Hash) == 0 {
+				t.Errorf("shell %t: %s not hashed", shell, rel)
+			}
+			names = append(names, rel)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("shell %t: decode: %s", shell, err)
+		}
+		sort.Strings(names)
+		want := []string{"a", "a/b", "a/b/h", "a/g", "f"}
+		if len(names) != len(want) {
+			t.Fatalf("shell %t: files %v, want %v", shell, names, want)
+		}
+		for i := range want {
+			if names[i] != want[i] {
+				t.Fatalf("shell %t: files %v, want %v", shell, names, want)
+			}
+		}
+		if !progress.Done || progress.Files != len(want) {
+			t.Errorf("shell %t: progress %+v", shell, progress)
+		}
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	req := wire.NewWalker().Header("/")
+	h := wire.NewWalker().Header("/")
+	h.Hostname = "other"
+	err := checkHeader(req, h)
+	if err != nil {
+		t.Errorf("hostname: %s", err)
+	}
+	h.HashAll = !req.HashAll
+	err = checkHeader(req, h)
+	if err == nil {
+		t.Error("hash all: no error")
+	}
+	err = checkHeader(req, &pb.StepHeader{})
+	if err == nil {
+		t.Error("version: no error")
+	}
+}
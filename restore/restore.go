@@ -0,0 +1,298 @@
+// Package restore recreates trees from snapshots, with content from embedded content or a blob store.
+package restore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+// ErrNoContent is returned for non-empty regular files whose content is neither embedded nor in the store.
+var ErrNoContent = errors.New("content not embedded or in the store")
+
+// Content opens the content of fi, from its embedded content or from s (which may be nil).
+func Content(fi *wire.FileInfo2, s *store.Store) (io.ReadCloser, error) {
+	switch {
+	case fi.Embedded:
+		return io.NopCloser(bytes.NewReader(fi.Content)), nil
+	case fi.Size == 0:
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	case s != nil && fi.StoreHash != nil:
+		return s.Open(fi.StoreHash)
+	default:
+		return nil, ErrNoContent
+	}
+}
+
+// Restorer recreates files in a directory.
+type Restorer struct {
+	// Target is the directory to restore into.
+	Target string
+	// Store has the content of files, if not nil.
+	Store *store.Store
+	// NoOwners does not change owners and groups (e.g. when not running as root).
+	NoOwners bool
+	// MetadataOnly only changes the metadata of existing files (of the same type) to match, without creating or writing any.
+	MetadataOnly bool
+	// Times also changes mtimes with MetadataOnly. Otherwise, mtimes are always restored.
+	Times bool
+	// DryRun only logs changes with MetadataOnly.
+	DryRun bool
+	// Xattrs is whether the snapshot has xattrs (see pb.StepHeader.Metadata), so that MetadataOnly removes xattrs not in it.
+	// Otherwise, only xattrs of files with some in the snapshot are repaired.
+	Xattrs bool
+	Logger *log.Logger
+
+	// dirs have their metadata restored by Finish, as restoring files in them changes their mtime (and their mode may not allow writing).
+	dirs []dirEntry
+}
+
+type dirEntry struct {
+	path string
+	fi   wire.FileInfo2
+}
+
+func (r *Restorer) logf(format string, a ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, a...)
+	}
+}
+
+// path returns the path of rel (relative to the snapshot's root) in Target, checking that it stays in Target.
+func (r *Restorer) path(rel string) (string, error) {
+	path := filepath.Join(r.Target, rel)
+	rel2, err := filepath.Rel(r.Target, path)
+	if err != nil || rel2 == ".." || strings.HasPrefix(rel2, "../") {
+		return "", fmt.Errorf("%s: outside the target", rel)
+	}
+	// a symlink restored earlier could point outside the target
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err == nil {
+		target, err := filepath.EvalSymlinks(r.Target)
+		if err != nil {
+			return "", err
+		}
+		rel2, err := filepath.Rel(target, parent)
+		if err != nil || rel2 == ".." || strings.HasPrefix(rel2, "../") {
+			return "", fmt.Errorf("%s: parent is a symlink outside the target", rel)
+		}
+	}
+	return path, nil
+}
+
+// File restores fi, at rel relative to the snapshot's root.
+func (r *Restorer) File(rel string, fi *wire.FileInfo2) error {
+	path, err := r.path(rel)
+	if err != nil {
+		return err
+	}
+	if r.MetadataOnly {
+		return r.repair(path, fi)
+	}
+	if fi.Mode.IsDir() {
+		err = os.MkdirAll(path, 0o700)
+		if err != nil {
+			return err
+		}
+		r.dirs = append(r.dirs, dirEntry{path, *fi})
+		return nil
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	switch fi.Mode.Type() {
+	case 0:
+		err = r.writeFile(path, fi)
+	case fs.ModeSymlink:
+		if fi.Link == "" {
+			return errors.New("symlink target not captured")
+		}
+		err = os.Symlink(fi.Link, path)
+	case fs.ModeNamedPipe:
+		err = mkfifo(path, 0o600)
+	default:
+		return fmt.Errorf("cannot restore %s files", wire.FileType(fi.Mode))
+	}
+	if err != nil {
+		return err
+	}
+	return r.setMetadata(path, fi, true)
+}
+
+func (r *Restorer) writeFile(path string, fi *wire.FileInfo2) error {
+	src, err := Content(fi, r.Store)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+// setMetadata sets the owner, mode, xattrs, and (if times) mtime of path to fi's.
+func (r *Restorer) setMetadata(path string, fi *wire.FileInfo2, times bool) error {
+	isLink := fi.Mode&fs.ModeSymlink != 0
+	if !r.NoOwners {
+		// before chmod, as chown clears setuid and setgid
+		err := os.Lchown(path, int(fi.Owner), int(fi.Group))
+		if err != nil {
+			return err
+		}
+	}
+	if isLink {
+		// symlinks have no mode, and their xattrs are not walked
+		if times && !fi.Mtime.IsZero() {
+			return lchtimes(path, fi.Mtime)
+		}
+		return nil
+	}
+	names := make([]string, 0, len(fi.Xattrs))
+	for name := range fi.Xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := setXattr(path, name, fi.Xattrs[name])
+		if err != nil {
+			return fmt.Errorf("xattr %s: %w", name, err)
+		}
+	}
+	err := os.Chmod(path, fi.Mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky))
+	if err != nil {
+		return err
+	}
+	if times && !fi.Mtime.IsZero() {
+		err = os.Chtimes(path, time.Now(), fi.Mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repair changes the metadata of the existing file at path to match fi.
+func (r *Restorer) repair(path string, fi *wire.FileInfo2) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.logf("%s: missing", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fi.Mode.Type() {
+		r.logf("%s: is a %s, not a %s", path, wire.FileType(info.Mode()), wire.FileType(fi.Mode))
+		return nil
+	}
+	current, err := lstat(path, info)
+	if err != nil {
+		return err
+	}
+	want := *fi
+	if r.NoOwners {
+		want.Owner, want.Group = current.Owner, current.Group
+	}
+	if !r.Times || want.Mtime.IsZero() {
+		want.Mtime = current.Mtime
+	}
+	xattrs := r.Xattrs || fi.Xattrs != nil
+	if !xattrs {
+		want.Xattrs = current.Xattrs
+	}
+	changed := false
+	for _, d := range wire.Compare(&current, &want, wire.CompareOptions{Xattrs: xattrs}) {
+		switch d.Field {
+		case "mode", "owner", "group", "mtime":
+			r.logf("%s: %s", path, d)
+			changed = true
+		default:
+			if strings.HasPrefix(d.Field, "xattr ") {
+				r.logf("%s: %s", path, d)
+				changed = true
+			}
+		}
+	}
+	if !changed || r.DryRun {
+		return nil
+	}
+	if xattrs {
+		names := make([]string, 0, len(current.Xattrs))
+		for name := range current.Xattrs {
+			if _, ok := want.Xattrs[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			err = removeXattr(path, name)
+			if err != nil {
+				return fmt.Errorf("xattr %s: %w", name, err)
+			}
+		}
+	}
+	return r.setMetadata(path, &want, r.Times)
+}
+
+// lstat returns the metadata of path, with info from os.Lstat, as compared by repair.
+func lstat(path string, info fs.FileInfo) (wire.FileInfo2, error) {
+	fi := wire.FileInfo2{
+		Mode:  info.Mode(),
+		Size:  uint64(info.Size()),
+		Name:  info.Name(),
+		Path:  filepath.Dir(path),
+		Mtime: info.ModTime(),
+	}
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		fi.Owner = sys.Uid
+		fi.Group = sys.Gid
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return fi, nil
+	}
+	var err error
+	fi.Xattrs, err = wire.GetXattrs(path)
+	return fi, err
+}
+
+// Finish restores the metadata of directories, deepest first.
+func (r *Restorer) Finish() error {
+	sort.SliceStable(r.dirs, func(i, j int) bool {
+		return strings.Count(r.dirs[i].path, "/") > strings.Count(r.dirs[j].path, "/")
+	})
+	var errs []string
+	for _, d := range r.dirs {
+		err := r.setMetadata(d.path, &d.fi, true)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", d.path, err))
+		}
+	}
+	r.dirs = nil
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
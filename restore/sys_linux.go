@@ -0,0 +1,27 @@
+package restore
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func setXattr(path, name string, value []byte) error {
+	return syscall.Setxattr(path, name, value, 0)
+}
+
+// removeXattr removes the xattr name of path itself (not of a symlink's target).
+func removeXattr(path, name string) error {
+	return unix.Lremovexattr(path, name)
+}
+
+func mkfifo(path string, mode uint32) error {
+	return syscall.Mkfifo(path, mode)
+}
+
+// lchtimes sets the mtime of the symlink at path itself.
+func lchtimes(path string, mtime time.Time) error {
+	ts := []unix.Timespec{{Nsec: unix.UTIME_OMIT}, unix.NsecToTimespec(mtime.UnixNano())}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, ts, unix.AT_SYMLINK_NOFOLLOW)
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package restore
+
+import (
+	"errors"
+	"time"
+)
+
+func setXattr(path, name string, value []byte) error {
+	return errors.New("xattrs are not supported on this platform")
+}
+
+func removeXattr(path, name string) error {
+	return errors.New("xattrs are not supported on this platform")
+}
+
+func mkfifo(path string, mode uint32) error {
+	return errors.New("fifos are not supported on this platform")
+}
+
+// lchtimes is a no-op, as symlink mtimes cannot be set portably.
+func lchtimes(path string, mtime time.Time) error {
+	return nil
+}
@@ -0,0 +1,210 @@
+package restore
+
+import (
+	"archive/tar"
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// PAX records written in addition to the standard ones and SCHILY.xattr.*.
+const (
+	// PAXHash is the hash of a file in hex, and PAXHashAlgorithm its algorithm (e.g. sha256).
+	PAXHash          = "HINO.hash"
+	PAXHashAlgorithm = "HINO.hashAlgorithm"
+	// PAXSize is the size of a regular file written without its content.
+	PAXSize = "HINO.size"
+)
+
+// Tar writes files to a POSIX (PAX) tar stream instead of a directory.
+type Tar struct {
+	w *tar.Writer
+	// Store has the content of files, if not nil.
+	Store *store.Store
+	// Names are used for the user and group names of entries, if not nil.
+	Names *wire.Names
+	// HashAlgorithm is the algorithm of hashes, recorded with them.
+	HashAlgorithm string
+	// Contents writes the content of regular files. Otherwise, they are empty, with their size in a PAXSize record.
+	Contents bool
+	// Live reads content neither embedded nor in the store from the files themselves, for a walk in progress.
+	Live bool
+}
+
+// NewTar returns a Tar writing to w.
+func NewTar(w io.Writer) *Tar {
+	return &Tar{w: tar.NewWriter(w), HashAlgorithm: "xxhash"}
+}
+
+// TarHeader returns the tar header of fi, at rel relative to the snapshot's root.
+func (t *Tar) TarHeader(rel string, fi *wire.FileInfo2) (*tar.Header, error) {
+	th := &tar.Header{
+		Name:    strings.TrimPrefix(rel, "/"),
+		Mode:    int64(wire.UnixMode(fi.Mode)),
+		Uid:     int(fi.Owner),
+		Gid:     int(fi.Group),
+		ModTime: fi.Mtime,
+		// PAX keeps mtimes to the nanosecond
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{},
+	}
+	if t.Names != nil {
+		th.Uname = t.Names.Users[fi.Owner]
+		th.Gname = t.Names.Groups[fi.Group]
+	}
+	switch fi.Mode.Type() {
+	case 0:
+		th.Typeflag = tar.TypeReg
+		if t.Contents {
+			th.Size = int64(fi.Size)
+		} else {
+			th.PAXRecords[PAXSize] = strconv.FormatUint(fi.Size, 10)
+		}
+	case fs.ModeDir:
+		th.Typeflag = tar.TypeDir
+		th.Name += "/"
+	case fs.ModeSymlink:
+		th.Typeflag = tar.TypeSymlink
+		th.Linkname = fi.Link
+	case fs.ModeNamedPipe:
+		th.Typeflag = tar.TypeFifo
+	default:
+		return nil, fmt.Errorf("cannot archive %s files", wire.FileType(fi.Mode))
+	}
+	if len(fi.Hash) != 0 {
+		th.PAXRecords[PAXHash] = hex.EncodeToString(fi.Hash)
+		th.PAXRecords[PAXHashAlgorithm] = t.HashAlgorithm
+	}
+	names := make([]string, 0, len(fi.Xattrs))
+	for name := range fi.Xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		th.PAXRecords["SCHILY.xattr."+name] = string(fi.Xattrs[name])
+	}
+	return th, nil
+}
+
+// content opens the content of fi, from the file itself if Live.
+func (t *Tar) content(fi *wire.FileInfo2) (io.ReadCloser, error) {
+	src, err := Content(fi, t.Store)
+	if err == ErrNoContent && t.Live {
+		return os.Open(filepath.Join(fi.Path, fi.Name))
+	}
+	return src, err
+}
+
+// File writes fi, at rel relative to the snapshot's root, with its content if Contents.
+func (t *Tar) File(rel string, fi *wire.FileInfo2) error {
+	_, err := t.file(rel, fi)
+	return err
+}
+
+// file is File, also returning whether the stream is broken (i.e. the error was after writing the header).
+func (t *Tar) file(rel string, fi *wire.FileInfo2) (broken bool, err error) {
+	th, err := t.TarHeader(rel, fi)
+	if err != nil {
+		return false, err
+	}
+	var src io.ReadCloser
+	if th.Typeflag == tar.TypeReg && t.Contents {
+		// check before writing the header, so that the stream stays valid
+		src, err = t.content(fi)
+		if err != nil {
+			return false, err
+		}
+		defer src.Close()
+	}
+	err = t.w.WriteHeader(th)
+	if err != nil {
+		return true, err
+	}
+	if src != nil {
+		// a live file may have changed since it was walked
+		w := &errWriter{w: t.w}
+		n, err := io.Copy(w, io.LimitReader(src, th.Size))
+		if w.err != nil {
+			return true, w.err
+		}
+		if n != th.Size {
+			// pad it, so that the stream stays valid
+			_, err2 := io.CopyN(w, zeros{}, th.Size-n)
+			if err2 != nil {
+				return true, err2
+			}
+			if err == nil {
+				err = fmt.Errorf("content is %d bytes, not %d (padded with zeros)", n, th.Size)
+			}
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// errWriter records whether an error was from writing (and not reading) with io.Copy.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+// zeros reads zeros forever.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// WriteSnapshot writes each file in the snapshot in r, with names and the hash algorithm from its header.
+// Files that cannot be written are logged and skipped, and counted in failed.
+func (t *Tar) WriteSnapshot(r io.Reader) (failed int, err error) {
+	root := "/"
+	err = wire.DecodeFiles(bufio.NewReader(r), func(h *pb.StepHeader) error {
+		root = h.Root
+		t.Names = wire.NamesFromHeader(h)
+		if h.HashAlgorithm != "" {
+			t.HashAlgorithm = h.HashAlgorithm
+		}
+		return nil
+	}, func(fi wire.FileInfo2) error {
+		rel := strings.TrimPrefix(wire.InRoot(root, filepath.Join(fi.Path, fi.Name)), "/")
+		broken, err := t.file(rel, &fi)
+		if broken {
+			return fmt.Errorf("%s: %w", rel, err)
+		}
+		if err != nil {
+			log.Printf("%s: %s", rel, err)
+			failed++
+		}
+		return nil
+	})
+	return failed, err
+}
+
+// Close finishes the tar stream, without closing the underlying writer.
+func (t *Tar) Close() error {
+	return t.w.Close()
+}
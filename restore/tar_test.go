@@ -0,0 +1,121 @@
+package restore
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// TestTarShrunk checks that a live file that shrank since it was walked is padded, without breaking the stream.
+func TestTarShrunk(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"shrunk": "abc", "ok": "content"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	tw := NewTar(&buf)
+	tw.Contents = true
+	tw.Live = true
+	mtime := time.Unix(1700000000, 0)
+	shrunk := &wire.FileInfo2{Mode: 0o644, Size: 10, Name: "shrunk", Path: dir, Mtime: mtime}
+	broken, err := tw.file("shrunk", shrunk)
+	if broken || err == nil {
+		t.Fatalf("shrunk: broken %t, error %v; want an error without breaking", broken, err)
+	}
+	ok := &wire.FileInfo2{Mode: fs.FileMode(0o644), Size: 7, Name: "ok", Path: dir, Mtime: mtime}
+	err = tw.File("ok", ok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"shrunk": "abc\x00\x00\x00\x00\x00\x00\x00", "ok": "content"}
+	tr := tar.NewReader(&buf)
+	n := 0
+	for {
+		th, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want[th.Name] {
+			t.Errorf("%s: content %q, want %q", th.Name, b, want[th.Name])
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Errorf("%d entries, want %d", n, len(want))
+	}
+}
+
+// TestTarSnapshotRelative checks that names are relative to a relative root, under which decoded paths start from /.
+func TestTarSnapshotRelative(t *testing.T) {
+	var buf bytes.Buffer
+	w := wire.NewWriter(&buf)
+	err := w.WriteHeader(&pb.StepHeader{Version: wire.HeaderVersion, Root: "./td"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1700000000, 0)
+	for _, fi := range []wire.FileInfo2{
+		{Mode: fs.ModeDir | 0o755, Name: "a", Path: "/td", Mtime: mtime},
+		{Mode: 0o644, Name: "f", Path: "/td/a", Mtime: mtime},
+	} {
+		err = w.Write(&fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	tw := NewTar(&out)
+	failed, err := tw.WriteSnapshot(&buf)
+	if err != nil || failed != 0 {
+		t.Fatalf("%d failed: %v", failed, err)
+	}
+	err = tw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/", "a/f"}
+	tr := tar.NewReader(&out)
+	for i := 0; ; i++ {
+		th, err := tr.Next()
+		if err == io.EOF {
+			if i != len(want) {
+				t.Errorf("%d entries, want %d", i, len(want))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i >= len(want) || th.Name != want[i] {
+			t.Errorf("entry %d: %s", i, th.Name)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package snapdb loads snapshots into an SQLite database for queries across snapshots.
+package snapdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	_ "modernc.org/sqlite"
+)
+
+// Schema is the schema of the database.
+// Paths are relative to the root of their snapshot (e.g. /usr/bin/su), and interned in paths.
+// Modes are Unix mode bits (e.g. 0o4755 for a setuid binary), and mtimes are in nanoseconds since the epoch.
+const Schema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	root TEXT NOT NULL,
+	hostname TEXT,
+	goos TEXT,
+	goarch TEXT,
+	profile TEXT,
+	hash_algorithm TEXT,
+	source TEXT
+);
+CREATE TABLE IF NOT EXISTS paths (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS entries (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	path_id INTEGER NOT NULL REFERENCES paths (id),
+	type TEXT NOT NULL,
+	mode INTEGER NOT NULL,
+	size INTEGER NOT NULL,
+	uid INTEGER NOT NULL,
+	gid INTEGER NOT NULL,
+	hash BLOB,
+	hash_err TEXT,
+	mtime INTEGER,
+	link TEXT,
+	mount_point INTEGER NOT NULL,
+	fs_type TEXT,
+	package TEXT,
+	package_version TEXT,
+	package_manager TEXT,
+	package_status INTEGER NOT NULL,
+	PRIMARY KEY (snapshot_id, path_id)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS entries_path ON entries (path_id);
+CREATE INDEX IF NOT EXISTS entries_hash ON entries (hash);
+CREATE TABLE IF NOT EXISTS xattrs (
+	snapshot_id INTEGER NOT NULL REFERENCES snapshots (id) ON DELETE CASCADE,
+	path_id INTEGER NOT NULL REFERENCES paths (id),
+	name TEXT NOT NULL,
+	value BLOB NOT NULL,
+	PRIMARY KEY (snapshot_id, path_id, name)
+) WITHOUT ROWID;
+CREATE VIEW IF NOT EXISTS files AS
+	SELECT snapshots.name AS snapshot, paths.path AS path, entries.*
+	FROM entries
+	JOIN snapshots ON snapshots.id = entries.snapshot_id
+	JOIN paths ON paths.id = entries.path_id;
+`
+
+// DB is a database of snapshots.
+type DB struct {
+	*sql.DB
+	// pathIDs caches the IDs of interned paths.
+	pathIDs map[string]int64
+}
+
+// Open opens (creating if necessary) the database at path, or a temporary one in memory if path is :memory:.
+func Open(path string) (*DB, error) {
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// each connection would have its own database
+		sqlDB.SetMaxOpenConns(1)
+	}
+	_, err = sqlDB.Exec(Schema)
+	if err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	return &DB{DB: sqlDB, pathIDs: map[string]int64{}}, nil
+}
+
+// Import loads the snapshot in r as name, replacing any snapshot with the same name.
+// Later steps for the same path (e.g. from hino-watch) replace earlier ones, and removals are applied.
+// It returns the number of files loaded.
+func (db *DB) Import(name string, r io.Reader) (n int, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	_, err = tx.Exec("DELETE FROM snapshots WHERE name = ?", name)
+	if err != nil {
+		return 0, err
+	}
+	insertPath, err := tx.Prepare("INSERT INTO paths (path) VALUES (?) ON CONFLICT (path) DO UPDATE SET path = path RETURNING id")
+	if err != nil {
+		return 0, err
+	}
+	defer insertPath.Close()
+	insertEntry, err := tx.Prepare(`INSERT INTO entries (
+		snapshot_id, path_id, type, mode, size, uid, gid, hash, hash_err, mtime, link,
+		mount_point, fs_type, package, package_version, package_manager, package_status
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT (snapshot_id, path_id) DO UPDATE SET
+		type = excluded.type, mode = excluded.mode, size = excluded.size, uid = excluded.uid, gid = excluded.gid,
+		hash = excluded.hash, hash_err = excluded.hash_err, mtime = excluded.mtime, link = excluded.link,
+		mount_point = excluded.mount_point, fs_type = excluded.fs_type, package = excluded.package,
+		package_version = excluded.package_version, package_manager = excluded.package_manager, package_status = excluded.package_status`)
+	if err != nil {
+		return 0, err
+	}
+	defer insertEntry.Close()
+	insertXattr, err := tx.Prepare("INSERT INTO xattrs (snapshot_id, path_id, name, value) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer insertXattr.Close()
+	deleteXattrs, err := tx.Prepare("DELETE FROM xattrs WHERE snapshot_id = ? AND path_id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer deleteXattrs.Close()
+
+	// new paths are only cached after commit, as they are rolled back otherwise
+	newPathIDs := map[string]int64{}
+	pathID := func(path string) (int64, error) {
+		if id, ok := db.pathIDs[path]; ok {
+			return id, nil
+		}
+		if id, ok := newPathIDs[path]; ok {
+			return id, nil
+		}
+		var id int64
+		err := insertPath.QueryRow(path).Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		newPathIDs[path] = id
+		return id, nil
+	}
+
+	var snapshotID int64
+	root := "/"
+	start := func() error {
+		if snapshotID != 0 {
+			return nil
+		}
+		return db.insertSnapshot(tx, name, &pb.StepHeader{Root: root}, &snapshotID)
+	}
+	_, err = wire.Decode(r, wire.Handlers{Header: func(h *pb.StepHeader) error {
+		root = h.Root
+		return db.insertSnapshot(tx, name, h, &snapshotID)
+	}, File: func(fi wire.FileInfo2) error {
+		err := start()
+		if err != nil {
+			return err
+		}
+		id, err := pathID(wire.InRoot(root, filepath.Join(fi.Path, fi.Name)))
+		if err != nil {
+			return fmt.Errorf("path: %w", err)
+		}
+		var mtime sql.NullInt64
+		if !fi.Mtime.IsZero() {
+			mtime = sql.NullInt64{Int64: fi.Mtime.UnixNano(), Valid: true}
+		}
+		var hash []byte
+		if len(fi.Hash) != 0 {
+			hash = fi.Hash
+		}
+		_, err = insertEntry.Exec(
+			snapshotID, id, wire.FileType(fi.Mode), wire.UnixMode(fi.Mode), fi.Size, fi.Owner, fi.Group,
+			hash, nullString(fi.HashErr), mtime, nullString(fi.Link),
+			fi.MountPoint, nullString(fi.FsType), nullString(fi.Package), nullString(fi.PackageVersion), nullString(fi.PackageManager), int32(fi.PackageStatus),
+		)
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", filepath.Join(fi.Path, fi.Name), err)
+		}
+		_, err = deleteXattrs.Exec(snapshotID, id)
+		if err != nil {
+			return fmt.Errorf("xattrs %s: %w", filepath.Join(fi.Path, fi.Name), err)
+		}
+		names := make([]string, 0, len(fi.Xattrs))
+		for name := range fi.Xattrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, err = insertXattr.Exec(snapshotID, id, name, fi.Xattrs[name])
+			if err != nil {
+				return fmt.Errorf("xattr %s: %w", filepath.Join(fi.Path, fi.Name), err)
+			}
+		}
+		return nil
+	}, Remove: func(path string) error {
+		err := start()
+		if err != nil {
+			return err
+		}
+		rel := wire.InRoot(root, path)
+		// and everything in it, if a directory
+		prefix := strings.TrimSuffix(rel, "/") + "/"
+		for _, table := range []string{"entries", "xattrs"} {
+			_, err = tx.Exec("DELETE FROM "+table+" WHERE snapshot_id = ? AND path_id IN (SELECT id FROM paths WHERE path = ? OR substr(path, 1, length(?)) = ?)", snapshotID, rel, prefix, prefix)
+			if err != nil {
+				return fmt.Errorf("remove %s: %w", path, err)
+			}
+		}
+		return nil
+	}})
+	if err != nil {
+		return 0, err
+	}
+	if snapshotID == 0 {
+		return 0, errors.New("no steps")
+	}
+	err = tx.QueryRow("SELECT count(*) FROM entries WHERE snapshot_id = ?", snapshotID).Scan(&n)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	for path, id := range newPathIDs {
+		db.pathIDs[path] = id
+	}
+	return n, nil
+}
+
+func (db *DB) insertSnapshot(tx *sql.Tx, name string, h *pb.StepHeader, id *int64) error {
+	hashAlgorithm := h.HashAlgorithm
+	if hashAlgorithm == "" {
+		hashAlgorithm = "xxhash"
+	}
+	err := tx.QueryRow(
+		"INSERT INTO snapshots (name, root, hostname, goos, goarch, profile, hash_algorithm, source) VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id",
+		name, h.Root, nullString(h.Hostname), nullString(h.Goos), nullString(h.Goarch), nullString(h.Profile), hashAlgorithm, nullString(h.Source),
+	).Scan(id)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
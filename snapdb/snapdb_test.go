@@ -0,0 +1,148 @@
+package snapdb
+
+import (
+	"bytes"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// testStep is a file to write, or a path to remove if fi is nil.
+type testStep struct {
+	fi     *wire.FileInfo2
+	remove string
+}
+
+// writeSnapshot returns steps as a snapshot of the relative root td, as decoded (under /td).
+func writeSnapshot(t *testing.T, steps []testStep) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := wire.NewWriter(&buf)
+	err := w.WriteHeader(&pb.StepHeader{Version: wire.HeaderVersion, Root: "td", Hostname: "h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range steps {
+		if s.fi == nil {
+			err = w.Remove(s.remove)
+		} else {
+			err = w.Write(s.fi)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImport(t *testing.T) {
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mtime := time.Unix(1700000000, 0)
+	file := func(dir, name string, size uint64, xattrs map[string][]byte) testStep {
+		return testStep{fi: &wire.FileInfo2{Path: dir, Name: name, Mode: 0o644, Size: size, Mtime: mtime, Hash: []byte(name), Xattrs: xattrs}}
+	}
+	dir := func(parent, name string) testStep {
+		return testStep{fi: &wire.FileInfo2{Path: parent, Name: name, Mode: fs.ModeDir | 0o755, Mtime: mtime}}
+	}
+	data := writeSnapshot(t, []testStep{
+		dir("/td", "a"),
+		file("/td/a", "f", 1, map[string][]byte{"user.x": []byte("1"), "user.y": []byte("2")}),
+		dir("/td", "b"),
+		file("/td/b", "g", 2, nil),
+		file("/td/b", "h", 3, nil),
+		file("/td", "bb", 4, nil),
+		// a later step for the same path, as appended by hino-watch
+		file("/td/a", "f", 5, map[string][]byte{"user.z": []byte("3")}),
+		{remove: "/td/b"},
+	})
+	for i := 0; i < 2; i++ {
+		// the second time replaces the first
+		n, err := db.Import("s", bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("import %d: %d files, want 3", i, n)
+		}
+	}
+
+	rows, err := db.Query("SELECT path, type, mode, size FROM files WHERE snapshot = ? ORDER BY path", "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type entry struct {
+		path, typ  string
+		mode, size int64
+	}
+	var got []entry
+	for rows.Next() {
+		var e entry
+		err = rows.Scan(&e.path, &e.typ, &e.mode, &e.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	err = rows.Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []entry{
+		{"/a", wire.FileType(fs.ModeDir), 0o755, 0},
+		{"/a/f", wire.FileType(0), 0o644, 5},
+		{"/bb", wire.FileType(0), 0o644, 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("files %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("file %d: %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	var xattrs []string
+	rows2, err := db.Query("SELECT name, value FROM xattrs JOIN paths ON paths.id = path_id WHERE path = '/a/f'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows2.Close()
+	for rows2.Next() {
+		var name, value string
+		err = rows2.Scan(&name, &value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xattrs = append(xattrs, name+"="+value)
+	}
+	if len(xattrs) != 1 || xattrs[0] != "user.z=3" {
+		t.Errorf("xattrs %v, want [user.z=3]", xattrs)
+	}
+
+	var root, hostname string
+	err = db.QueryRow("SELECT root, hostname FROM snapshots WHERE name = 's'").Scan(&root, &hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != "td" || hostname != "h" {
+		t.Errorf("snapshot root %q hostname %q", root, hostname)
+	}
+
+	_, err = db.Import("empty", bytes.NewReader(nil))
+	if err == nil {
+		t.Error("empty: no error")
+	}
+}
@@ -0,0 +1,179 @@
+// Package store implements a content-addressed blob store, keyed by SHA-256.
+//
+// Blobs are at sha256/<first 2 hex digits>/<hex> in the store's directory.
+// Blobs are written to tmp/ first and renamed into place, so concurrent writers (even in other processes) are safe.
+package store
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Store is a blob store in a directory.
+type Store struct {
+	dir string
+}
+
+// Open opens (creating if necessary) the store in dir.
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"sha256", "tmp"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir returns the directory of s.
+func (s *Store) Dir() string { return s.dir }
+
+// Path returns the path of the blob with SHA-256 hash.
+func (s *Store) Path(hash []byte) string {
+	h := hex.EncodeToString(hash)
+	if len(h) < 2 {
+		return filepath.Join(s.dir, "sha256", h)
+	}
+	return filepath.Join(s.dir, "sha256", h[:2], h)
+}
+
+// Has reports whether the blob with SHA-256 hash is in s.
+func (s *Store) Has(hash []byte) bool {
+	_, err := os.Stat(s.Path(hash))
+	return err == nil
+}
+
+// Open opens the blob with SHA-256 hash.
+func (s *Store) Open(hash []byte) (*os.File, error) {
+	return os.Open(s.Path(hash))
+}
+
+// PutFile copies the content of the regular file at path into s, returning its SHA-256.
+// If known is the SHA-256 of the content and it is already in s, the file is not read.
+func (s *Store) PutFile(path string, known []byte) ([]byte, error) {
+	if len(known) == sha256.Size && s.Has(known) {
+		s.touch(known)
+		return known, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return s.Put(f)
+}
+
+// Put copies the content of r into s, returning its SHA-256.
+func (s *Store) Put(r io.Reader) ([]byte, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "blob-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if err1 := tmp.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return nil, err
+	}
+	hash := h.Sum(nil)
+	if s.Has(hash) {
+		s.touch(hash)
+		return hash, nil
+	}
+	path := s.Path(hash)
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(tmp.Name(), 0o444)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return nil, err
+	}
+	return hash, nil
+}
+
+// touch updates the mtime of the blob with SHA-256 hash, so that it is as recent as a new blob (see hino-gc -grace).
+// Errors are ignored, as the blob may belong to another user.
+func (s *Store) touch(hash []byte) {
+	now := time.Now()
+	_ = os.Chtimes(s.Path(hash), now, now)
+}
+
+// Verify checks that the blob with SHA-256 hash has that hash.
+func (s *Store) Verify(hash []byte) error {
+	f, err := s.Open(hash)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), hash) {
+		return fmt.Errorf("blob %x has hash %x", hash, h.Sum(nil))
+	}
+	return nil
+}
+
+// Walk calls fn with the SHA-256 and size of each blob in s.
+func (s *Store) Walk(fn func(hash []byte, size int64) error) error {
+	return filepath.WalkDir(filepath.Join(s.dir, "sha256"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		hash, err := hex.DecodeString(d.Name())
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("%s: not a blob", path)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(hash, info.Size())
+	})
+}
+
+// Remove removes the blob with SHA-256 hash.
+func (s *Store) Remove(hash []byte) error {
+	err := os.Remove(s.Path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Temps returns the paths of temporary files left by interrupted writes (and writes in progress).
+func (s *Store) Temps() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "tmp"))
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "blob-") {
+			paths = append(paths, filepath.Join(s.dir, "tmp", e.Name()))
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPut(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := s.Put(strings.NewReader("content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Has(hash) {
+		t.Fatal("not stored")
+	}
+	err = s.Verify(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := s.Open(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b) != "content" {
+		t.Fatalf("read %q, %v", b, err)
+	}
+	temps, err := s.Temps()
+	if err != nil || len(temps) != 0 {
+		t.Fatalf("temps %v, %v", temps, err)
+	}
+}
+
+// TestPutTouches checks that storing a blob again makes it recent, so hino-gc keeps it.
+func TestPutTouches(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "f")
+	err = os.WriteFile(path, []byte("content"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := s.PutFile(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-72 * time.Hour)
+	for _, known := range [][]byte{nil, hash} {
+		err = os.Chtimes(s.Path(hash), old, old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.PutFile(path, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(s.Path(hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Since(info.ModTime()) > time.Hour {
+			t.Errorf("known %x: mtime %s not updated", known, info.ModTime())
+		}
+	}
+}
@@ -0,0 +1,169 @@
+// Package textdiff makes unified diffs of text.
+package textdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// op is an edit of a line.
+type op struct {
+	Kind byte // ' ', '-', or '+'
+	Line string
+}
+
+// Unified returns a unified diff (with context lines of context) from a, named aName, to b, named bName.
+// It returns an empty string if a and b are the same.
+func Unified(aName, bName string, a, b []byte, context int) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	if bytes.IndexByte(a, 0) != -1 || bytes.IndexByte(b, 0) != -1 {
+		return fmt.Sprintf("Binary files %s and %s differ\n", aName, bName)
+	}
+	as := splitLines(string(a))
+	bs := splitLines(string(b))
+	ops := diff(as, bs)
+
+	out := new(strings.Builder)
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", aName, bName)
+	// ai and bi are the line numbers (from 0) before each op
+	ai, bi := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, o := range ops {
+		ai[i+1], bi[i+1] = ai[i], bi[i]
+		if o.Kind != '+' {
+			ai[i+1]++
+		}
+		if o.Kind != '-' {
+			bi[i+1]++
+		}
+	}
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == ' ' {
+			i++
+			continue
+		}
+		// a hunk from start to end, merging changes up to 2*context apart
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(ai[start], ai[end]), hunkRange(bi[start], bi[end]))
+		for _, o := range ops[start:end] {
+			out.WriteByte(o.Kind)
+			out.WriteString(o.Line)
+			if !strings.HasSuffix(o.Line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats lines from start to end (from 0) as in a hunk header.
+func hunkRange(start, end int) string {
+	n := end - start
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// splitLines splits s into lines, keeping newlines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diff returns the edits from a to b, using Myers' algorithm.
+func diff(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		// only keys -d-1 to d+1 are needed when backtracking from d
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+	panic("unreachable")
+}
+
+func backtrack(a, b []string, trace [][]int, d int) []op {
+	var ops []op
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, op{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, op{'-', a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		ops = append(ops, op{' ', a[x]})
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
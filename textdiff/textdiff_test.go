@@ -0,0 +1,84 @@
+package textdiff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lines returns the lines 1 to n, with changed lines replaced.
+func lines(n int, changed map[int]string) string {
+	b := new(strings.Builder)
+	for i := 1; i <= n; i++ {
+		if s, ok := changed[i]; ok {
+			b.WriteString(s)
+		} else {
+			b.WriteString(strconv.Itoa(i))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// The expected diffs are as from GNU diff -U.
+func TestUnified(t *testing.T) {
+	ten := lines(10, nil)
+	for _, c := range []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"same", "a\n", "a\n", 3, ""},
+		{"both empty", "", "", 3, ""},
+		{"from empty", "", "x\ny\n", 3, "@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"to empty", "x\ny\n", "", 3, "@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"newline removed", "a\n", "a", 3, "@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{"no newlines", "a", "b", 3, "@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n\\ No newline at end of file\n"},
+		{"newline added", "a\nb", "a\nb\nc\n", 3, "@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n"},
+		{"context is clipped", "a\nb\n", "a\nc\n", 3, "@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+		// 5 unchanged lines are more than 2*2 apart
+		{"split", ten, lines(10, map[int]string{3: "X", 9: "Y"}), 2,
+			"@@ -1,5 +1,5 @@\n 1\n 2\n-3\n+X\n 4\n 5\n@@ -7,4 +7,4 @@\n 7\n 8\n-9\n+Y\n 10\n"},
+		// but 4 are not
+		{"merged", ten, lines(10, map[int]string{3: "X", 8: "Y"}), 2,
+			"@@ -1,10 +1,10 @@\n 1\n 2\n-3\n+X\n 4\n 5\n 6\n 7\n-8\n+Y\n 9\n 10\n"},
+		{"merged at 2*3", ten, lines(10, map[int]string{3: "X", 10: "Y"}), 3,
+			"@@ -1,10 +1,10 @@\n 1\n 2\n-3\n+X\n 4\n 5\n 6\n 7\n 8\n 9\n-10\n+Y\n"},
+		{"insertion without context", ten, strings.Replace(ten, "6\n", "X\n6\n", 1), 0, "@@ -5,0 +6 @@\n+X\n"},
+		{"deletion without context", "1\n2\n3\n", "1\n3\n", 0, "@@ -2 +1,0 @@\n-2\n"},
+		{"split without context", "1\n2\n3\n", "X\n2\nY\n", 0, "@@ -1 +1 @@\n-1\n+X\n@@ -3 +3 @@\n-3\n+Y\n"},
+	} {
+		got := Unified("a", "b", []byte(c.a), []byte(c.b), c.context)
+		want := c.want
+		if want != "" {
+			want = "--- a\n+++ b\n" + want
+		}
+		if got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", c.name, got, want)
+		}
+	}
+
+	got := Unified("a", "b", []byte("a\x00"), []byte("b"), 3)
+	if got != "Binary files a and b differ\n" {
+		t.Errorf("binary: %q", got)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"\n", []string{"\n"}},
+		{"a", []string{"a"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n\n", []string{"a\n", "\n"}},
+	} {
+		got := splitLines(c.s)
+		if strings.Join(got, "|") != strings.Join(c.want, "|") || len(got) != len(c.want) {
+			t.Errorf("%q: %q, want %q", c.s, got, c.want)
+		}
+	}
+}
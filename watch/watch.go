@@ -0,0 +1,59 @@
+// Package watch follows changes to a tree, writing them as steps after a walk of it.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+// stepWriter writes file and removal steps for absolute paths, as a walker walks a root.
+type stepWriter struct {
+	wr *wire.Writer
+}
+
+// file writes the step for the file at path, as walker walks root, unless it no longer exists or is not walked.
+func (s *stepWriter) file(walker *wire.Walker, root, path string) error {
+	sf, blob, err := walker.StatFile(root, path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// its removal follows
+		return nil
+	}
+	if err != nil {
+		log.Printf("stat %s: %s", path, err)
+		return nil
+	}
+	if sf == nil {
+		return nil
+	}
+	fi := wire.NewFileInfo2(sf, filepath.Dir(path))
+	if blob != nil {
+		fi.Content = blob.Content
+	}
+	return s.wr.Write(&fi)
+}
+
+// remove writes the removal of path.
+func (s *stepWriter) remove(path string) error {
+	return s.wr.Remove(path)
+}
+
+// Flusher is implemented by writers that buffer (e.g. bufio.Writer), flushed after each batch of changes.
+type Flusher interface {
+	Flush() error
+}
+
+func flush(out io.Writer) error {
+	if f, ok := out.(Flusher); ok {
+		err := f.Flush()
+		if err != nil {
+			return fmt.Errorf("flush: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+const mask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_ONLYDIR | syscall.IN_DONT_FOLLOW | syscall.IN_EXCL_UNLINK
+
+// Watcher watches the directories of a tree with inotify.
+type Watcher struct {
+	root   string
+	walker *wire.Walker
+	fd     int
+	// dirs are the directories watched, by watch descriptor.
+	dirs map[int32]string
+	wds  map[string]int32
+}
+
+// New returns a Watcher watching the directories in root not blocked by walker.
+// Create it before walking, so that changes during the walk are not missed.
+func New(root string, walker *wire.Walker) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("inotify: %w", err)
+	}
+	w := &Watcher{
+		root:   filepath.Clean(root),
+		walker: walker,
+		fd:     fd,
+		dirs:   map[int32]string{},
+		wds:    map[string]int32{},
+	}
+	err = w.addTree(w.root, nil)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	log.Printf("watching %d directories", len(w.dirs))
+	return w, nil
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	return syscall.Close(w.fd)
+}
+
+// addTree watches dir and the directories under it, calling fn (if not nil) with the path of each file under it.
+func (w *Watcher) addTree(dir string, fn func(path string) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("walk %s: %s", path, err)
+			return nil
+		}
+		if path != dir {
+			if w.walker.Blocked(w.root, path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if fn != nil {
+				if err := fn(path); err != nil {
+					return err
+				}
+			}
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := syscall.InotifyAddWatch(w.fd, path, mask)
+		if err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				return fmt.Errorf("watch %s: %w (raise fs.inotify.max_user_watches)", path, err)
+			}
+			log.Printf("watch %s: %s", path, err)
+			return nil
+		}
+		w.dirs[int32(wd)] = path
+		w.wds[path] = int32(wd)
+		return nil
+	})
+}
+
+// forget stops watching dir and the directories under it (e.g. as it was moved away).
+func (w *Watcher) forget(dir string) {
+	prefix := dir + "/"
+	for path, wd := range w.wds {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, path)
+			delete(w.dirs, wd)
+		}
+	}
+}
+
+// Run writes steps for each change to out, starting in the directory dir (see wire.Walker.Walk2Dir), until an error.
+// Out is flushed after each batch of changes if it is a Flusher.
+func (w *Watcher) Run(out io.Writer, dir string) error {
+	s := &stepWriter{wr: wire.NewWriterIn(out, dir)}
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := syscall.Read(w.fd, buf)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(e.Len)]
+			off += syscall.SizeofInotifyEvent + int(e.Len)
+			err = w.handle(s, e.Wd, e.Mask, string(bytes.TrimRight(nameBytes, "\x00")))
+			if err != nil {
+				return err
+			}
+		}
+		if len(w.dirs) == 0 {
+			return errors.New("no directories left to watch")
+		}
+		err = flush(out)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// event is what an inotify event means for the tree.
+type event struct {
+	// path is the file changed, created, or removed, or "" if the event is for none.
+	path  string
+	isDir bool
+	// created is whether path is new in its directory (created or moved in), so a directory's contents must be walked.
+	created bool
+	removed bool
+	// dirChanged is whether the directory containing path changed (i.e. its mtime), so its step must be written again.
+	dirChanged bool
+}
+
+// parseEvent returns what the event with mask m for name, in the watched directory dir of the tree at root, means.
+func parseEvent(root, dir string, m uint32, name string) event {
+	if name == "" {
+		// the directory itself, reported with its name in its parent's watch
+		return event{}
+	}
+	e := event{
+		path:  filepath.Join(dir, name),
+		isDir: m&syscall.IN_ISDIR != 0,
+	}
+	switch {
+	case m&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+		e.removed = true
+	case m&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		e.created = true
+	}
+	e.dirChanged = (e.removed || e.created) && dir != root
+	return e
+}
+
+// handle writes steps for an event.
+func (w *Watcher) handle(s *stepWriter, wd int32, m uint32, name string) error {
+	if m&syscall.IN_Q_OVERFLOW != 0 {
+		log.Printf("events were lost, so changes may be missing until the next walk")
+		return nil
+	}
+	dir, ok := w.dirs[wd]
+	if !ok {
+		return nil
+	}
+	if m&syscall.IN_IGNORED != 0 {
+		// the directory was removed or unmounted
+		delete(w.dirs, wd)
+		delete(w.wds, dir)
+		return nil
+	}
+	e := parseEvent(w.root, dir, m, name)
+	if e.path == "" || w.walker.Blocked(w.root, e.path, e.isDir) {
+		return nil
+	}
+	var err error
+	switch {
+	case e.removed:
+		if e.isDir {
+			w.forget(e.path)
+		}
+		err = s.remove(e.path)
+	case e.created && e.isDir:
+		err = s.file(w.walker, w.root, e.path)
+		if err != nil {
+			return err
+		}
+		// files may have been created in it before it was watched
+		err = w.addTree(e.path, func(path string) error {
+			return s.file(w.walker, w.root, path)
+		})
+	default:
+		err = s.file(w.walker, w.root, e.path)
+	}
+	if err != nil {
+		return err
+	}
+	if e.dirChanged {
+		// its mtime changed
+		return s.file(w.walker, w.root, dir)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package watch
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, c := range []struct {
+		dir  string
+		m    uint32
+		name string
+		want event
+	}{
+		{"/r/a", syscall.IN_CLOSE_WRITE, "f", event{path: "/r/a/f"}},
+		{"/r/a", syscall.IN_ATTRIB, "f", event{path: "/r/a/f"}},
+		{"/r/a", syscall.IN_ATTRIB | syscall.IN_ISDIR, "d", event{path: "/r/a/d", isDir: true}},
+		{"/r/a", syscall.IN_CREATE, "f", event{path: "/r/a/f", created: true, dirChanged: true}},
+		{"/r/a", syscall.IN_MOVED_TO, "f", event{path: "/r/a/f", created: true, dirChanged: true}},
+		{"/r/a", syscall.IN_CREATE | syscall.IN_ISDIR, "d", event{path: "/r/a/d", isDir: true, created: true, dirChanged: true}},
+		{"/r/a", syscall.IN_DELETE, "f", event{path: "/r/a/f", removed: true, dirChanged: true}},
+		{"/r/a", syscall.IN_MOVED_FROM, "f", event{path: "/r/a/f", removed: true, dirChanged: true}},
+		{"/r/a", syscall.IN_DELETE | syscall.IN_ISDIR, "d", event{path: "/r/a/d", isDir: true, removed: true, dirChanged: true}},
+		// the root is not written again
+		{"/r", syscall.IN_CREATE, "f", event{path: "/r/f", created: true}},
+		{"/r", syscall.IN_DELETE, "f", event{path: "/r/f", removed: true}},
+		{"/r", syscall.IN_CLOSE_WRITE, "f", event{path: "/r/f"}},
+		// for the watched directory itself
+		{"/r/a", syscall.IN_ATTRIB | syscall.IN_ISDIR, "", event{}},
+		{"/r/a", syscall.IN_DELETE_SELF, "", event{}},
+	} {
+		got := parseEvent("/r", c.dir, c.m, c.name)
+		if got != c.want {
+			t.Errorf("%s %#x %q: %+v, want %+v", c.dir, c.m, c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package watch
+
+import (
+	"errors"
+	"io"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+// Watcher watches the directories of a tree. It is only supported on Linux.
+type Watcher struct{}
+
+// New returns an error, as watching is only supported on Linux.
+func New(root string, walker *wire.Walker) (*Watcher, error) {
+	return nil, errors.New("watching is only supported on Linux")
+}
+
+// Close does nothing.
+func (w *Watcher) Close() error {
+	return nil
+}
+
+// Run returns an error, as watching is only supported on Linux.
+func (w *Watcher) Run(out io.Writer, dir string) error {
+	return errors.New("watching is only supported on Linux")
+}
@@ -0,0 +1,95 @@
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Difference is a difference in a field between two FileInfo2.
+type Difference struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s %s -> %s", d.Field, d.Old, d.New)
+}
+
+// CompareOptions selects what Compare compares.
+type CompareOptions struct {
+	// Quick only compares size and mtime.
+	Quick bool
+	// Xattrs compares extended attributes. Only set this if both have xattrs collected.
+	Xattrs bool
+	// ByName compares owners and groups by their names in OldNames and NewNames (falling back to IDs), instead of by ID.
+	ByName             bool
+	OldNames, NewNames *Names
+}
+
+// Compare returns the differences from a to b.
+// Mtimes and hashes are only compared if both have them.
+func Compare(a, b *FileInfo2, opts CompareOptions) []Difference {
+	var ds []Difference
+	add := func(field string, old, new interface{}) {
+		ds = append(ds, Difference{Field: field, Old: fmt.Sprint(old), New: fmt.Sprint(new)})
+	}
+	// the size of a directory depends on the filesystem, not its contents
+	if a.Size != b.Size && !(a.Mode.IsDir() && b.Mode.IsDir()) {
+		add("size", a.Size, b.Size)
+	}
+	if !a.Mtime.IsZero() && !b.Mtime.IsZero() && !a.Mtime.Equal(b.Mtime) {
+		add("mtime", a.Mtime.Format(time.RFC3339Nano), b.Mtime.Format(time.RFC3339Nano))
+	}
+	if opts.Quick {
+		return ds
+	}
+	if a.Mode != b.Mode {
+		add("mode", a.Mode, b.Mode)
+	}
+	if opts.ByName {
+		if oa, ob := opts.OldNames.User(a.Owner), opts.NewNames.User(b.Owner); oa != ob {
+			add("owner", oa, ob)
+		}
+		if ga, gb := opts.OldNames.Group(a.Group), opts.NewNames.Group(b.Group); ga != gb {
+			add("group", ga, gb)
+		}
+	} else {
+		if a.Owner != b.Owner {
+			add("owner", opts.OldNames.UserID(a.Owner), opts.NewNames.UserID(b.Owner))
+		}
+		if a.Group != b.Group {
+			add("group", opts.OldNames.GroupID(a.Group), opts.NewNames.GroupID(b.Group))
+		}
+	}
+	if a.Link != b.Link {
+		add("link", a.Link, b.Link)
+	}
+	if len(a.Hash) != 0 && len(b.Hash) != 0 && !bytes.Equal(a.Hash, b.Hash) {
+		add("hash", fmt.Sprintf("%x", a.Hash), fmt.Sprintf("%x", b.Hash))
+	}
+	if opts.Xattrs {
+		names := map[string]bool{}
+		for name := range a.Xattrs {
+			names[name] = true
+		}
+		for name := range b.Xattrs {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			va, oka := a.Xattrs[name]
+			vb, okb := b.Xattrs[name]
+			if oka != okb || !bytes.Equal(va, vb) {
+				add("xattr "+name, fmt.Sprintf("%q", va), fmt.Sprintf("%q", vb))
+			}
+		}
+	}
+	return ds
+}
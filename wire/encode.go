@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/fs"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/nyiyui/opt/hinomori/wire/pb"
 	"google.golang.org/protobuf/proto"
@@ -32,18 +34,12 @@ func EncodeStep(w io.Writer, wire *pb.Step) error {
 }
 
 // DecodeSteps decodes the "step" wire format into pb.Step, described in wire.md.
+// It returns io.EOF after the last step.
 func DecodeSteps(r io.Reader, steps chan<- *pb.Step) error {
 	defer close(steps)
-	var magic [4]byte
-	_, err := r.Read(magic[:])
-	if err != nil {
-		return err
-	}
-	if string(magic[:]) != "hino" {
-		return errors.New("invalid magic")
-	}
+	rd := NewReader(r)
 	for {
-		step, err := decodeSingle(r)
+		step, err := rd.step()
 		if err != nil {
 			return err
 		}
@@ -51,57 +47,215 @@ func DecodeSteps(r io.Reader, steps chan<- *pb.Step) error {
 	}
 }
 
-func decodeSingle(r io.Reader) (*pb.Step, error) {
-	var lenBytes [8]byte
-	_, err := io.ReadFull(r, lenBytes[:])
+// DecodeStep decodes a single step in "step" wire format.
+func DecodeStep(r io.Reader) (*pb.Step, error) {
+	step, _, err := decodeStep(r)
+	return step, err
+}
+
+// decodeStep decodes a single step in "step" wire format, also returning its bytes (for checksums).
+func decodeStep(r io.Reader) (*pb.Step, []byte, error) {
+	buf := make([]byte, 8)
+	_, err := io.ReadFull(r, buf)
 	if err != nil {
-		return nil, fmt.Errorf("read size: %w", err)
+		return nil, nil, fmt.Errorf("read size: %w", err)
+	}
+	size := binary.LittleEndian.Uint64(buf)
+	buf = append(buf, make([]byte, size)...)
+	n, err := io.ReadFull(r, buf[8:])
+	if err == io.EOF {
+		// the size was read, so the step is truncated
+		err = io.ErrUnexpectedEOF
 	}
-	size := binary.LittleEndian.Uint64(lenBytes[:])
-	buf := make([]byte, size)
-	n, err := io.ReadFull(r, buf)
 	if err != nil {
-		return nil, fmt.Errorf("read data of size %d: %w", size, err)
+		return nil, nil, fmt.Errorf("read data of size %d: %w", size, err)
 	}
 	if n != int(size) {
-		return nil, fmt.Errorf("underread: want %d got %d", size, n)
+		return nil, nil, fmt.Errorf("underread: want %d got %d", size, n)
 	}
 	var step pb.Step
-	err = proto.Unmarshal(buf, &step)
+	err = proto.Unmarshal(buf[8:], &step)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	return &step, buf, nil
+}
+
+// NewFileInfo2 returns a FileInfo2 for f in the directory path.
+func NewFileInfo2(f *pb.StepFile, path string) FileInfo2 {
+	fi := FileInfo2{
+		Mode:  fs.FileMode(f.Mode),
+		Size:  f.Size,
+		Name:  f.Name,
+		Path:  path,
+		Hash:  f.Hash,
+		Owner: f.Own,
+		Group: f.Grp,
+
+		HashErr: f.HashErr,
+		Link:    f.Link,
+		Xattrs:  f.Xattrs,
+
+		MountPoint: f.MountPoint,
+		FsType:     f.FsType,
+
+		Package:        f.Package,
+		PackageVersion: f.PackageVersion,
+		PackageManager: f.PackageManager,
+		PackageStatus:  f.PackageStatus,
+
+		Embedded:  f.Embedded,
+		StoreHash: f.StoreHash,
+	}
+	if f.Mtime != 0 {
+		fi.Mtime = time.Unix(0, f.Mtime)
+	}
+	return fi
+}
+
+// StepFile returns f as a pb.StepFile, the reverse of NewFileInfo2.
+func (f *FileInfo2) StepFile() *pb.StepFile {
+	sf := &pb.StepFile{
+		Mode:    uint32(f.Mode),
+		Own:     f.Owner,
+		Grp:     f.Group,
+		Size:    f.Size,
+		Name:    f.Name,
+		Hash:    f.Hash,
+		HashErr: f.HashErr,
+		Link:    f.Link,
+		Xattrs:  f.Xattrs,
+
+		MountPoint: f.MountPoint,
+		FsType:     f.FsType,
+
+		Package:        f.Package,
+		PackageVersion: f.PackageVersion,
+		PackageManager: f.PackageManager,
+		PackageStatus:  f.PackageStatus,
+
+		Embedded:  f.Embedded,
+		StoreHash: f.StoreHash,
+	}
+	if !f.Mtime.IsZero() {
+		sf.Mtime = f.Mtime.UnixNano()
+	}
+	return sf
+}
+
+// EncodeFiles writes h and files in "file" wire format.
+// Files are sorted by directory, so they may be in any order.
+func EncodeFiles(w io.Writer, h *pb.StepHeader, files []FileInfo2) error {
+	return EncodeIncremental(w, h, files, nil)
+}
+
+// EncodeIncremental is EncodeFiles, also writing the removal of each (absolute) path in removed.
+func EncodeIncremental(w io.Writer, h *pb.StepHeader, files []FileInfo2, removed []string) error {
+	wr := NewWriter(w)
+	err := wr.WriteHeader(h)
+	if err != nil {
+		return err
 	}
-	return &step, nil
+	type entry struct {
+		dir string
+		fi  *FileInfo2
+		// path is the path to remove if fi is nil
+		path string
+	}
+	entries := make([]entry, 0, len(files)+len(removed))
+	for i := range files {
+		entries = append(entries, entry{dir: filepath.Clean(files[i].Path), fi: &files[i]})
+	}
+	for _, path := range removed {
+		entries = append(entries, entry{dir: filepath.Dir(path), path: path})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].dir < entries[j].dir
+	})
+	for _, e := range entries {
+		if e.fi == nil {
+			err = wr.Remove(e.path)
+		} else {
+			err = wr.Write(e.fi)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// ConvertSteps converts a channel of pb.Step into FileInfo2.
+// ConvertSteps converts a channel of pb.Step (after the magic) into FileInfo2.
+// It stops at the first invalid step, sending the error to errs (which must be received from),
+// as the directories of later files may no longer be known; the rest of in is drained, so that the sender does not block.
 func ConvertSteps(in <-chan *pb.Step, out chan<- FileInfo2, errs chan<- error) {
 	defer close(out)
 	defer close(errs)
-	currentPath := "/"
-	for step := range in {
-		switch stepIn := step.Step.(type) {
+	r := newChanReader(in)
+	for {
+		fi, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			errs <- err
+			for range in {
+			}
+			return
+		}
+		out <- *fi
+	}
+}
+
+// DecodeFiles decodes the "file" wire format from r until EOF, calling header with the header (if any) and file with each file.
+// Either callback may be nil. Removals in incremental snapshots are ignored.
+func DecodeFiles(r io.Reader, header func(*pb.StepHeader) error, file func(FileInfo2) error) error {
+	_, err := Decode(r, Handlers{Header: header, File: file})
+	return err
+}
+
+// Handlers are called by Decode for each kind of step. Any may be nil.
+type Handlers struct {
+	Header func(*pb.StepHeader) error
+	File   func(FileInfo2) error
+	// Remove is called with the (absolute) path of each removal in an incremental snapshot.
+	Remove func(path string) error
+	// Trailer is called with the trailer, after its checksum is verified.
+	Trailer func(*pb.StepTrailer) error
+}
+
+// Decode decodes the "file" wire format from r until EOF, calling hs.
+// It returns the checksum of r (see StepTrailer), i.e. the SHA-256 of r before the trailer, or of all of r if it has none.
+func Decode(r io.Reader, hs Handlers) (checksum []byte, err error) {
+	rd := NewReader(r)
+	for {
+		step, err := rd.step()
+		if errors.Is(err, io.EOF) {
+			return rd.Checksum(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch s := step.Step.(type) {
+		case *pb.Step_Header:
+			if hs.Header != nil {
+				err = hs.Header(s.Header)
+			}
 		case *pb.Step_File:
-			f := stepIn.File
-			fi := FileInfo2{
-				Mode:  fs.FileMode(f.Mode),
-				Size:  f.Size,
-				Name:  f.Name,
-				Path:  currentPath,
-				Hash:  f.Hash,
-				Owner: f.Own,
-				Group: f.Grp,
+			if hs.File != nil {
+				err = hs.File(*rd.fileInfo(s.File))
 			}
-			out <- fi
-		case *pb.Step_Up:
-			up := int(stepIn.Up.Up)
-			for i := 0; i < up; i++ {
-				currentPath = filepath.Join(currentPath, "..")
+		case *pb.Step_Remove:
+			if hs.Remove != nil {
+				err = hs.Remove(filepath.Join(rd.Dir(), s.Remove.Name))
 			}
-		case *pb.Step_Down:
-			currentPath = filepath.Join(currentPath, string(stepIn.Down.Down))
-		default:
-			errs <- errors.New("invalid Step")
+		case *pb.Step_Trailer:
+			if hs.Trailer != nil {
+				err = hs.Trailer(s.Trailer)
+			}
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
 }
@@ -0,0 +1,118 @@
+package wire
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+// HinoignoreName is the name of files with block rules for their directory.
+const HinoignoreName = ".hinoignore"
+
+// readHinoignore returns set with the rules in dir's HinoignoreName file, if any.
+func (w *Walker) readHinoignore(dir string, set *rules.Set) *rules.Set {
+	rs, err := rules.ReadFile(filepath.Join(dir, HinoignoreName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("read %s: %s", HinoignoreName, err)
+		}
+		return set
+	}
+	return set.Child(dir, rs)
+}
+
+// Explain returns why path is or is not blocked or hashed when walking root.
+func (w *Walker) Explain(root, path string) ([]string, error) {
+	root = filepath.Clean(root)
+	path = filepath.Clean(path)
+	if path != root && !strings.HasPrefix(path, strings.TrimSuffix(root, "/")+"/") {
+		return nil, fmt.Errorf("%s is not in %s", path, root)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+
+	blocked := false
+	for _, re := range w.blockedPaths {
+		if re.MatchString(path) {
+			lines = append(lines, fmt.Sprintf("blocked by regexp %q", re))
+			blocked = true
+		}
+	}
+	set := rules.NewSet(root, w.blockRules)
+	if w.hinoignore {
+		set = w.readHinoignore(root, set)
+	}
+	if path != root {
+		rel := strings.TrimPrefix(path, strings.TrimSuffix(root, "/")+"/")
+		dir := root
+		parts := strings.Split(rel, "/")
+		for _, part := range parts[:len(parts)-1] {
+			dir = filepath.Join(dir, part)
+			if r := set.Match(dir, true); r != nil && !r.Negate {
+				lines = append(lines, fmt.Sprintf("blocked as ancestor %s is blocked by rule %s", dir, r))
+				blocked = true
+			}
+			if w.hinoignore {
+				set = w.readHinoignore(dir, set)
+			}
+		}
+	}
+	if r := set.Match(path, info.IsDir()); r != nil {
+		if r.Negate {
+			lines = append(lines, fmt.Sprintf("not blocked by negated rule %s", r))
+		} else {
+			lines = append(lines, fmt.Sprintf("blocked by rule %s", r))
+			blocked = true
+		}
+	}
+	if !blocked {
+		lines = append(lines, "not blocked")
+	}
+
+	hashed := false
+	if w.hashAll {
+		lines = append(lines, "hashed as all files are hashed")
+		hashed = true
+	}
+	for _, re := range w.hashPaths {
+		if re.MatchString(path) {
+			lines = append(lines, fmt.Sprintf("hashed by regexp %q", re))
+			hashed = true
+		}
+	}
+	if r := rules.NewSet(root, w.hashRules).Match(path, info.IsDir()); r != nil {
+		if r.Negate {
+			lines = append(lines, fmt.Sprintf("not hashed by negated rule %s", r))
+		} else {
+			lines = append(lines, fmt.Sprintf("hashed by rule %s", r))
+			hashed = true
+		}
+	}
+	if !hashed {
+		lines = append(lines, "not hashed")
+	} else if !info.Mode().IsRegular() {
+		lines = append(lines, "not hashed as it is not a regular file")
+	} else if w.maxHashSize != 0 && uint64(info.Size()) > w.maxHashSize {
+		lines = append(lines, fmt.Sprintf("not hashed as it is larger than %d bytes", w.maxHashSize))
+	}
+
+	if r := rules.NewSet(root, w.contentRules).Match(path, false); r == nil {
+		lines = append(lines, "content not embedded")
+	} else if r.Negate {
+		lines = append(lines, fmt.Sprintf("content not embedded by negated rule %s", r))
+	} else if !info.Mode().IsRegular() {
+		lines = append(lines, "content not embedded as it is not a regular file")
+	} else if uint64(info.Size()) > w.contentLimit() {
+		lines = append(lines, fmt.Sprintf("content not embedded as it is larger than %d bytes", w.contentLimit()))
+	} else {
+		lines = append(lines, fmt.Sprintf("content embedded by rule %s", r))
+	}
+	return lines, nil
+}
@@ -0,0 +1,129 @@
+package wire
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+// TestHinoignore checks Blocked, Explain, and walking agree on nested .hinoignore files.
+func TestHinoignore(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		HinoignoreName:                     "*.log\n!keep.log\nbuild/\n",
+		"a.log":                            "",
+		"keep.log":                         "",
+		"sub/" + HinoignoreName:            "!*.log\nsecret\n",
+		"sub/b.log":                        "",
+		"sub/secret":                       "",
+		"sub/ok":                           "",
+		"sub/deep/" + HinoignoreName:       "*.log\n",
+		"sub/deep/c.log":                   "",
+		"build/x":                          "",
+		"build/" + HinoignoreName:          "!x\n",
+		"hashed.bin":                       "",
+		"header-blocked/" + HinoignoreName: "",
+	} {
+		path := filepath.Join(root, name)
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockRules, err := rules.ParseLines([]string{"/header-blocked/"}, "block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashRules, err := rules.ParseLines([]string{"*.bin"}, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWalker()
+	w.Hinoignore(true)
+	w.BlockRules(blockRules)
+	w.HashRules(hashRules)
+
+	for _, c := range []struct {
+		name    string
+		blocked bool
+		explain string
+	}{
+		{"a.log", true, `blocked by rule "*.log" (` + filepath.Join(root, HinoignoreName) + ":1)"},
+		{"keep.log", false, `not blocked by negated rule "!keep.log"`},
+		{"sub/b.log", false, `not blocked by negated rule "!*.log" (` + filepath.Join(root, "sub", HinoignoreName) + ":1)"},
+		{"sub/secret", true, `blocked by rule "secret"`},
+		{"sub/ok", false, "not blocked"},
+		{"sub/deep/c.log", true, `blocked by rule "*.log" (` + filepath.Join(root, "sub", "deep", HinoignoreName) + ":1)"},
+		// a file cannot be re-included if its directory is blocked
+		{"build", true, `blocked by rule "build/"`},
+		{"build/x", true, "blocked as ancestor " + filepath.Join(root, "build") + ` is blocked by rule "build/"`},
+		{"header-blocked", true, `blocked by rule "/header-blocked/" (block:1)`},
+		{"hashed.bin", false, `hashed by rule "*.bin" (hash:1)`},
+	} {
+		path := filepath.Join(root, c.name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Blocked(root, path, info.IsDir()); got != c.blocked {
+			t.Errorf("%s: blocked %t, want %t", c.name, got, c.blocked)
+		}
+		lines, err := w.Explain(root, path)
+		if err != nil {
+			t.Errorf("%s: explain: %s", c.name, err)
+			continue
+		}
+		found := false
+		notBlocked := false
+		for _, line := range lines {
+			if strings.HasPrefix(line, c.explain) {
+				found = true
+			}
+			if line == "not blocked" {
+				notBlocked = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: explain %q, want %q", c.name, lines, c.explain)
+		}
+		if notBlocked == c.blocked {
+			t.Errorf("%s: explain %q disagrees with blocked %t", c.name, lines, c.blocked)
+		}
+	}
+	_, err = w.Explain(root, "/elsewhere")
+	if err == nil {
+		t.Error("outside the root: no error")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(WireMagic)
+	err = w.Walk2(root, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = DecodeFiles(&buf, nil, func(fi FileInfo2) error {
+		names = append(names, strings.TrimPrefix(InRoot(root, filepath.Join(fi.Path, fi.Name)), "/"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	want := []string{
+		HinoignoreName, "hashed.bin", "keep.log", "sub",
+		"sub/" + HinoignoreName, "sub/b.log", "sub/deep", "sub/deep/" + HinoignoreName, "sub/ok",
+	}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("walked %q, want %q", names, want)
+	}
+}
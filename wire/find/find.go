@@ -0,0 +1,376 @@
+// Package find implements find(1)-like expressions over files in snapshots.
+//
+// Primaries:
+//
+//	-name glob       base name matches glob
+//	-iname glob      like -name, but case-insensitive
+//	-path glob       path (relative to the snapshot's root, e.g. /usr/bin/su) matches glob; * and ? also match /
+//	-regex re        path matches the whole of the regular expression re (Go syntax)
+//	-type t          type is one of t (comma-separated f, d, l, b, c, p, or s)
+//	-perm mode       permission bits (in octal, including setuid etc.) are exactly mode,
+//	                 -perm -mode all of mode are set, and -perm /mode any of mode are set
+//	-size n[ckMG]    size is n bytes (or KiB etc., rounded up like find(1), so -size -1k only matches empty files),
+//	                 +n more than n, and -n less than n
+//	-uid n, -gid n   owner or group is n, +n more than n, and -n less than n
+//	-hashed          has a hash
+//	-hash hex        hash starts with hex
+//	-hash-error      hashing failed
+//	-true, -false    always or never matches
+//
+// Operators, from highest precedence:
+//
+//	( expr )
+//	! expr, -not expr
+//	expr expr, expr -a expr, expr -and expr
+//	expr -o expr, expr -or expr
+package find
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+// File is a file to match.
+type File struct {
+	wire.FileInfo2
+	// RelPath is the path relative to the root of the snapshot, as an absolute path.
+	RelPath string
+}
+
+// Expr is an expression.
+type Expr interface {
+	Match(f *File) bool
+}
+
+type and struct{ a, b Expr }
+
+func (e and) Match(f *File) bool { return e.a.Match(f) && e.b.Match(f) }
+
+type or struct{ a, b Expr }
+
+func (e or) Match(f *File) bool { return e.a.Match(f) || e.b.Match(f) }
+
+type not struct{ a Expr }
+
+func (e not) Match(f *File) bool { return !e.a.Match(f) }
+
+// predicate is a primary.
+type predicate func(f *File) bool
+
+func (p predicate) Match(f *File) bool { return p(f) }
+
+// True matches all files.
+var True Expr = predicate(func(*File) bool { return true })
+
+// Parse parses args (e.g. []string{"-type", "f", "-perm", "-4000"}) into an expression.
+// No args is True.
+func Parse(args []string) (Expr, error) {
+	if len(args) == 0 {
+		return True, nil
+	}
+	p := &parser{args: args}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(p.args) {
+		return nil, fmt.Errorf("unexpected %s", p.args[p.i])
+	}
+	return e, nil
+}
+
+type parser struct {
+	args []string
+	i    int
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.i >= len(p.args) {
+		return "", false
+	}
+	return p.args[p.i], true
+}
+
+func (p *parser) next() (string, bool) {
+	arg, ok := p.peek()
+	if ok {
+		p.i++
+	}
+	return arg, ok
+}
+
+func (p *parser) or() (Expr, error) {
+	e, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok || (arg != "-o" && arg != "-or") {
+			return e, nil
+		}
+		p.i++
+		e2, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		e = or{e, e2}
+	}
+}
+
+func (p *parser) and() (Expr, error) {
+	e, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok || arg == "-o" || arg == "-or" || arg == ")" {
+			return e, nil
+		}
+		if arg == "-a" || arg == "-and" {
+			p.i++
+		}
+		e2, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		e = and{e, e2}
+	}
+}
+
+func (p *parser) unary() (Expr, error) {
+	arg, ok := p.next()
+	if !ok {
+		return nil, errors.New("expected an expression")
+	}
+	switch arg {
+	case ")", "-o", "-or", "-a", "-and":
+		return nil, fmt.Errorf("unexpected %s", arg)
+	case "!", "-not":
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	case "(":
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if arg, ok := p.next(); !ok || arg != ")" {
+			return nil, errors.New("expected )")
+		}
+		return e, nil
+	}
+	return p.primary(arg)
+}
+
+func (p *parser) primary(name string) (Expr, error) {
+	switch name {
+	case "-true":
+		return True, nil
+	case "-false":
+		return not{True}, nil
+	case "-hashed":
+		return predicate(func(f *File) bool { return len(f.Hash) != 0 }), nil
+	case "-hash-error":
+		return predicate(func(f *File) bool { return f.HashErr != "" }), nil
+	}
+	arg, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("%s: missing argument", name)
+	}
+	e, err := primary(name, arg)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", name, arg, err)
+	}
+	return e, nil
+}
+
+func primary(name, arg string) (Expr, error) {
+	switch name {
+	case "-name", "-iname":
+		re, err := globRegexp(arg, name == "-iname")
+		if err != nil {
+			return nil, err
+		}
+		return predicate(func(f *File) bool { return re.MatchString(f.Name) }), nil
+	case "-path":
+		re, err := globRegexp(arg, false)
+		if err != nil {
+			return nil, err
+		}
+		return predicate(func(f *File) bool { return re.MatchString(f.RelPath) }), nil
+	case "-regex":
+		re, err := regexp.Compile("^(?:" + arg + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return predicate(func(f *File) bool { return re.MatchString(f.RelPath) }), nil
+	case "-type":
+		var types []fs.FileMode
+		for _, t := range strings.Split(arg, ",") {
+			typ, ok := fileTypes[t]
+			if !ok {
+				return nil, fmt.Errorf("unknown type %s", t)
+			}
+			types = append(types, typ)
+		}
+		return predicate(func(f *File) bool {
+			for _, typ := range types {
+				if f.Mode.Type() == typ {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "-perm":
+		return parsePerm(arg)
+	case "-size":
+		return parseCompare(arg, true, func(f *File) uint64 { return f.Size })
+	case "-uid":
+		return parseCompare(arg, false, func(f *File) uint64 { return uint64(f.Owner) })
+	case "-gid":
+		return parseCompare(arg, false, func(f *File) uint64 { return uint64(f.Group) })
+	case "-hash":
+		prefix, err := hex.DecodeString(arg)
+		if err != nil {
+			return nil, err
+		}
+		return predicate(func(f *File) bool { return len(f.Hash) != 0 && bytes.HasPrefix(f.Hash, prefix) }), nil
+	default:
+		return nil, errors.New("unknown primary")
+	}
+}
+
+var fileTypes = map[string]fs.FileMode{
+	"f": 0,
+	"d": fs.ModeDir,
+	"l": fs.ModeSymlink,
+	"b": fs.ModeDevice,
+	"c": fs.ModeDevice | fs.ModeCharDevice,
+	"p": fs.ModeNamedPipe,
+	"s": fs.ModeSocket,
+}
+
+func parsePerm(arg string) (Expr, error) {
+	op := byte(0)
+	if strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "/") {
+		op = arg[0]
+		arg = arg[1:]
+	}
+	n, err := strconv.ParseUint(arg, 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	mode := uint32(n)
+	if mode&^0o7777 != 0 {
+		return nil, errors.New("mode out of range")
+	}
+	switch op {
+	case '-':
+		return predicate(func(f *File) bool { return wire.UnixMode(f.Mode)&mode == mode }), nil
+	case '/':
+		// like GNU find, /0 matches everything
+		return predicate(func(f *File) bool { return mode == 0 || wire.UnixMode(f.Mode)&mode != 0 }), nil
+	default:
+		return predicate(func(f *File) bool { return wire.UnixMode(f.Mode) == mode }), nil
+	}
+}
+
+var sizeUnits = map[byte]uint64{
+	'c': 1,
+	'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+}
+
+// parseCompare parses n, +n, or -n (with a unit suffix if units is true) into a comparison with value,
+// rounded up to the unit.
+func parseCompare(arg string, units bool, value func(f *File) uint64) (Expr, error) {
+	op := byte(0)
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		op = arg[0]
+		arg = arg[1:]
+	}
+	unit := uint64(1)
+	if units && arg != "" {
+		if u, ok := sizeUnits[arg[len(arg)-1]]; ok {
+			unit = u
+			arg = arg[:len(arg)-1]
+		}
+	}
+	n, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	rounded := func(f *File) uint64 {
+		v := value(f)
+		if v%unit != 0 {
+			return v/unit + 1
+		}
+		return v / unit
+	}
+	switch op {
+	case '+':
+		return predicate(func(f *File) bool { return rounded(f) > n }), nil
+	case '-':
+		return predicate(func(f *File) bool { return rounded(f) < n }), nil
+	default:
+		return predicate(func(f *File) bool { return rounded(f) == n }), nil
+	}
+}
+
+// globRegexp converts a glob to a regular expression, where * and ? also match /.
+func globRegexp(glob string, fold bool) (*regexp.Regexp, error) {
+	b := new(strings.Builder)
+	if fold {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end == -1 {
+				return nil, errors.New("unterminated [")
+			}
+			if end == 0 {
+				// ] right after [ is part of the class
+				end2 := strings.IndexByte(glob[i+2:], ']')
+				if end2 == -1 {
+					return nil, errors.New("unterminated [")
+				}
+				end = end2 + 1
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
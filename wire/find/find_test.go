@@ -0,0 +1,235 @@
+package find
+
+import (
+	"io/fs"
+	"path"
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire"
+)
+
+func testFile(relPath string, mode fs.FileMode, size uint64) *File {
+	return &File{FileInfo2: wire.FileInfo2{Name: path.Base(relPath), Mode: mode, Size: size, Owner: 1000, Group: 100}, RelPath: relPath}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{"("},
+		{"(", "-true"},
+		{")"},
+		{"-true", ")"},
+		{"!"},
+		{"-not"},
+		{"-o", "-true"},
+		{"-true", "-o"},
+		{"-true", "-a"},
+		{"-true", "-a", "-o", "-true"},
+		{"(", ")"},
+		{"-name"},
+		{"-bogus", "x"},
+		{"-name", "[a"},
+		{"-regex", "("},
+		{"-type", "x"},
+		{"-type", "f,"},
+		{"-perm", "8"},
+		{"-perm", "-10000"},
+		{"-perm", "u+s"},
+		{"-size", "1x"},
+		{"-size", "k"},
+		{"-size", "+"},
+		{"-uid", "1k"},
+		{"-hash", "xyz"},
+	} {
+		_, err := Parse(args)
+		if err == nil {
+			t.Errorf("%q: no error", args)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	a := testFile("/a", 0o644, 0)
+	b := testFile("/b", 0o644, 0)
+	c := testFile("/c", 0o644, 0)
+	for _, tc := range []struct {
+		args []string
+		// want is whether /a, /b, and /c match
+		want [3]bool
+	}{
+		{nil, [3]bool{true, true, true}},
+		{[]string{"-false"}, [3]bool{false, false, false}},
+		{[]string{"-name", "a"}, [3]bool{true, false, false}},
+		{[]string{"!", "-name", "a"}, [3]bool{false, true, true}},
+		{[]string{"-not", "-name", "a"}, [3]bool{false, true, true}},
+		{[]string{"!", "!", "-name", "a"}, [3]bool{true, false, false}},
+		{[]string{"-name", "a", "-o", "-name", "b"}, [3]bool{true, true, false}},
+		{[]string{"-name", "a", "-or", "-name", "b"}, [3]bool{true, true, false}},
+		// and binds tighter than or
+		{[]string{"-name", "a", "-o", "-name", "b", "-false"}, [3]bool{true, false, false}},
+		{[]string{"-name", "a", "-o", "-name", "b", "-a", "-false"}, [3]bool{true, false, false}},
+		{[]string{"-name", "a", "-o", "-name", "b", "-and", "-false"}, [3]bool{true, false, false}},
+		{[]string{"-false", "-name", "a", "-o", "-name", "b"}, [3]bool{false, true, false}},
+		{[]string{"(", "-name", "a", "-o", "-name", "b", ")", "-false"}, [3]bool{false, false, false}},
+		{[]string{"-false", "(", "-name", "a", "-o", "-name", "b", ")"}, [3]bool{false, false, false}},
+		// ! binds tighter than and
+		{[]string{"!", "-name", "a", "-name", "b"}, [3]bool{false, true, false}},
+		{[]string{"!", "-name", "a", "-o", "-name", "a"}, [3]bool{true, true, true}},
+		{[]string{"!", "(", "-name", "a", "-o", "-name", "b", ")"}, [3]bool{false, false, true}},
+		{[]string{"(", "(", "-name", "c", ")", ")"}, [3]bool{false, false, true}},
+	} {
+		e, err := Parse(tc.args)
+		if err != nil {
+			t.Errorf("%q: %s", tc.args, err)
+			continue
+		}
+		for i, f := range []*File{a, b, c} {
+			if got := e.Match(f); got != tc.want[i] {
+				t.Errorf("%q: %s: %t, want %t", tc.args, f.RelPath, got, tc.want[i])
+			}
+		}
+	}
+}
+
+func TestPrimaries(t *testing.T) {
+	hashed := testFile("/usr/bin/su", 0o755|fs.ModeSetuid, 100)
+	hashed.Hash = []byte{0xab, 0xcd, 0xef}
+	failed := testFile("/etc/shadow", 0o640, 1000)
+	failed.HashErr = "permission denied"
+	for _, tc := range []struct {
+		args []string
+		f    *File
+		want bool
+	}{
+		// -name matches the base name, -path the whole path, with * and ? also matching /
+		{[]string{"-name", "su"}, hashed, true},
+		{[]string{"-name", "s?"}, hashed, true},
+		{[]string{"-name", "s"}, hashed, false},
+		{[]string{"-name", "bin"}, hashed, false},
+		{[]string{"-name", "[rs]u"}, hashed, true},
+		{[]string{"-name", "[!s]u"}, hashed, false},
+		{[]string{"-name", "[]s]u"}, hashed, true},
+		{[]string{"-name", `\*`}, testFile("/*", 0o644, 0), true},
+		{[]string{"-name", `\*`}, hashed, false},
+		{[]string{"-name", "SU"}, hashed, false},
+		{[]string{"-iname", "SU"}, hashed, true},
+		{[]string{"-path", "/usr/*"}, hashed, true},
+		{[]string{"-path", "/usr/b?n/su"}, hashed, true},
+		{[]string{"-path", "/usr*su"}, hashed, true},
+		{[]string{"-path", "/usr/?"}, hashed, false},
+		{[]string{"-path", "usr/bin/su"}, hashed, false},
+		{[]string{"-path", "/usr/bin/s.*"}, hashed, false},
+		{[]string{"-regex", "/usr/.*"}, hashed, true},
+		{[]string{"-regex", "/usr"}, hashed, false},
+		{[]string{"-type", "f"}, hashed, true},
+		{[]string{"-type", "d"}, hashed, false},
+		{[]string{"-type", "d,f"}, hashed, true},
+		{[]string{"-type", "l"}, testFile("/l", fs.ModeSymlink|0o777, 0), true},
+		{[]string{"-type", "b"}, testFile("/dev/sda", fs.ModeDevice|0o660, 0), true},
+		{[]string{"-type", "c"}, testFile("/dev/sda", fs.ModeDevice|0o660, 0), false},
+		{[]string{"-type", "c"}, testFile("/dev/null", fs.ModeDevice|fs.ModeCharDevice|0o666, 0), true},
+		{[]string{"-uid", "1000"}, hashed, true},
+		{[]string{"-uid", "+999"}, hashed, true},
+		{[]string{"-uid", "-1000"}, hashed, false},
+		{[]string{"-gid", "100"}, hashed, true},
+		{[]string{"-hashed"}, hashed, true},
+		{[]string{"-hashed"}, failed, false},
+		{[]string{"-hash", "abcd"}, hashed, true},
+		{[]string{"-hash", "ABCD"}, hashed, true},
+		{[]string{"-hash", "abce"}, hashed, false},
+		{[]string{"-hash", ""}, hashed, true},
+		{[]string{"-hash", ""}, failed, false},
+		{[]string{"-hash-error"}, failed, true},
+		{[]string{"-hash-error"}, hashed, false},
+	} {
+		e, err := Parse(tc.args)
+		if err != nil {
+			t.Errorf("%q: %s", tc.args, err)
+			continue
+		}
+		if got := e.Match(tc.f); got != tc.want {
+			t.Errorf("%q: %s: %t, want %t", tc.args, tc.f.RelPath, got, tc.want)
+		}
+	}
+}
+
+func TestPerm(t *testing.T) {
+	for _, tc := range []struct {
+		perm string
+		mode fs.FileMode
+		want bool
+	}{
+		// exact
+		{"755", 0o755, true},
+		{"755", 0o754, false},
+		{"755", 0o755 | fs.ModeSetuid, false},
+		{"4755", 0o755 | fs.ModeSetuid, true},
+		{"1777", 0o777 | fs.ModeSticky | fs.ModeDir, true},
+		{"0", 0, true},
+		// all of
+		{"-4000", 0o755 | fs.ModeSetuid, true},
+		{"-4000", 0o755, false},
+		{"-6000", 0o755 | fs.ModeSetuid, false},
+		{"-6000", 0o755 | fs.ModeSetuid | fs.ModeSetgid, true},
+		{"-022", 0o777, true},
+		{"-022", 0o755, false},
+		{"-0", 0o644, true},
+		// any of
+		{"/6000", 0o755 | fs.ModeSetgid, true},
+		{"/6000", 0o755, false},
+		{"/022", 0o645, false},
+		{"/022", 0o664, true},
+		{"/0", 0o644, true},
+	} {
+		e, err := Parse([]string{"-perm", tc.perm})
+		if err != nil {
+			t.Errorf("%s: %s", tc.perm, err)
+			continue
+		}
+		if got := e.Match(testFile("/f", tc.mode, 0)); got != tc.want {
+			t.Errorf("-perm %s: %s: %t, want %t", tc.perm, tc.mode, got, tc.want)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	for _, tc := range []struct {
+		size string
+		n    uint64
+		want bool
+	}{
+		{"0", 0, true},
+		{"100", 100, true},
+		{"100", 101, false},
+		{"100c", 100, true},
+		{"+100", 101, true},
+		{"+100", 100, false},
+		{"-100", 99, true},
+		{"-100", 100, false},
+		// sizes are rounded up to the unit
+		{"1k", 1, true},
+		{"1k", 1024, true},
+		{"1k", 1025, false},
+		{"2k", 1025, true},
+		{"0k", 0, true},
+		{"0k", 1, false},
+		{"-1k", 0, true},
+		{"-1k", 1, false},
+		{"-1M", 1000, false},
+		{"+1k", 1024, false},
+		{"+1k", 1025, true},
+		{"1M", 1 << 20, true},
+		{"1M", 1<<20 + 1, false},
+		{"1G", 1 << 30, true},
+		{"+1G", 1<<30 + 1, true},
+		{"18446744073709551615G", 1 << 62, false},
+	} {
+		e, err := Parse([]string{"-size", tc.size})
+		if err != nil {
+			t.Errorf("%s: %s", tc.size, err)
+			continue
+		}
+		if got := e.Match(testFile("/f", 0o644, tc.n)); got != tc.want {
+			t.Errorf("-size %s: %d: %t, want %t", tc.size, tc.n, got, tc.want)
+		}
+	}
+}
@@ -1,15 +1,78 @@
 package wire
 
 import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"sort"
 
 	"github.com/cespare/xxhash"
 )
 
+// xxhashLE is xxhash with the sum in little endian, as hashes were originally written.
+type xxhashLE struct {
+	hash.Hash64
+}
+
+func (h xxhashLE) Sum(b []byte) []byte {
+	var b2 [8]byte
+	binary.LittleEndian.PutUint64(b2[:], h.Sum64())
+	return append(b, b2[:]...)
+}
+
+// HashAlgorithms are the supported hash algorithms by name.
+var HashAlgorithms = map[string]func() hash.Hash{
+	"xxhash": func() hash.Hash { return xxhashLE{xxhash.New()} },
+	"sha256": sha256.New,
+	// md5 and sha1 are for verifying digests in package databases.
+	"md5":  md5.New,
+	"sha1": sha1.New,
+}
+
+// HashAlgorithmNames returns the names of HashAlgorithms, sorted.
+func HashAlgorithmNames() []string {
+	names := make([]string, 0, len(HashAlgorithms))
+	for name := range HashAlgorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (w *Walker) makeHash(path string) ([]byte, error) {
+	return HashFile(path, w.hashAlgorithm)
+}
+
+// HashBytes hashes b using the named algorithm.
+// Empty algorithm means xxhash.
+func HashBytes(b []byte, algorithm string) ([]byte, error) {
+	if algorithm == "" {
+		algorithm = "xxhash"
+	}
+	newHash, ok := HashAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %s", algorithm)
+	}
+	h := newHash()
+	h.Write(b)
+	return h.Sum(nil), nil
+}
+
+// HashFile hashes the regular file at path using the named algorithm.
+// Empty algorithm means xxhash.
+func HashFile(path string, algorithm string) ([]byte, error) {
+	if algorithm == "" {
+		algorithm = "xxhash"
+	}
+	newHash, ok := HashAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %s", algorithm)
+	}
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open: %w", err)
@@ -27,12 +90,10 @@ func (w *Walker) makeHash(path string) ([]byte, error) {
 	if !info.Mode().IsRegular() {
 		return nil, nil
 	}
-	digest := xxhash.New()
+	digest := newHash()
 	_, err = io.Copy(digest, f)
 	if err != nil {
 		return nil, fmt.Errorf("copy: %w", err)
 	}
-	var b [8]byte
-	binary.LittleEndian.PutUint64(b[:], digest.Sum64())
-	return b[:], nil
+	return digest.Sum(nil), nil
 }
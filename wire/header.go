@@ -0,0 +1,177 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+
+	"github.com/nyiyui/opt/hinomori/pkgdb"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+// HeaderVersion is the version of the header written by this package.
+const HeaderVersion = 1
+
+// Header returns a header describing how w walks root.
+func (w *Walker) Header(root string) *pb.StepHeader {
+	h := &pb.StepHeader{
+		Version: HeaderVersion,
+		Root:    root,
+		HashAll: w.hashAll,
+		Goos:    runtime.GOOS,
+		Goarch:  runtime.GOARCH,
+
+		Profile:       w.profile,
+		HashAlgorithm: w.hashAlgorithm,
+		MaxHashSize:   w.maxHashSize,
+		MaxDepth:      w.maxDepth,
+		BlockRules:    w.blockRules.Patterns(),
+		HashRules:     w.hashRules.Patterns(),
+		Hinoignore:    w.hinoignore,
+		OneFileSystem: w.oneFileSystem,
+		SkipFsTypes:   w.skipFsTypes,
+		Store:         w.store != nil,
+	}
+	if len(w.contentRules) != 0 {
+		h.ContentRules = w.contentRules.Patterns()
+		h.MaxContentSize = w.contentLimit()
+	}
+	h.Hostname, _ = os.Hostname()
+	for _, path := range w.blockedPaths {
+		h.Block = append(h.Block, path.String())
+	}
+	for _, path := range w.hashPaths {
+		h.Hash = append(h.Hash, path.String())
+	}
+	if w.packages != nil {
+		h.Packages = true
+		h.PackageManagers = w.packages.Managers
+	}
+	for _, name := range Metadata {
+		if w.metadata[name] {
+			h.Metadata = append(h.Metadata, name)
+		}
+	}
+	names, err := ReadNames(root)
+	if err != nil {
+		log.Printf("names: %s", err)
+	} else {
+		h.Users = names.Users
+		h.Groups = names.Groups
+	}
+	return h
+}
+
+// NewWalkerFromHeader returns a new Walker that walks the same way as described in h.
+// Unlike NewWalker, no paths are blocked by default.
+func NewWalkerFromHeader(h *pb.StepHeader) (*Walker, error) {
+	w := new(Walker)
+	w.HashAll(h.HashAll)
+	paths, err := CompilePaths(h.Block)
+	if err != nil {
+		return nil, fmt.Errorf("block: %w", err)
+	}
+	w.Block(paths)
+	paths, err = CompilePaths(h.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("hash: %w", err)
+	}
+	w.Hash(paths)
+	err = w.HashAlgorithm(h.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Metadata(h.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	w.MaxHashSize(h.MaxHashSize)
+	w.MaxDepth(h.MaxDepth)
+	w.Profile(h.Profile)
+	rs, err := rules.ParseLines(h.BlockRules, "header blockRules")
+	if err != nil {
+		return nil, fmt.Errorf("block rules: %w", err)
+	}
+	w.BlockRules(rs)
+	rs, err = rules.ParseLines(h.HashRules, "header hashRules")
+	if err != nil {
+		return nil, fmt.Errorf("hash rules: %w", err)
+	}
+	w.HashRules(rs)
+	rs, err = rules.ParseLines(h.ContentRules, "header contentRules")
+	if err != nil {
+		return nil, fmt.Errorf("content rules: %w", err)
+	}
+	w.ContentRules(rs)
+	w.MaxContentSize(h.MaxContentSize)
+	w.Hinoignore(h.Hinoignore)
+	w.OneFileSystem(h.OneFileSystem)
+	w.SkipFsTypes(h.SkipFsTypes)
+	if h.Packages {
+		db, err := pkgdb.Load(h.Root)
+		if err != nil {
+			return nil, fmt.Errorf("packages: %w", err)
+		}
+		w.Packages(db)
+	}
+	return w, nil
+}
+
+// CompilePaths compiles each regular expression in paths.
+func CompilePaths(paths []string) ([]*regexp.Regexp, error) {
+	paths2 := make([]*regexp.Regexp, len(paths))
+	for i, path := range paths {
+		var err error
+		paths2[i], err = regexp.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("path %d: %w", i, err)
+		}
+	}
+	return paths2, nil
+}
+
+// JSONPaths compiles each regular expression in s, a JSON array of strings (e.g. from a flag).
+func JSONPaths(s string) ([]*regexp.Regexp, error) {
+	paths := make([]string, 0)
+	err := json.Unmarshal([]byte(s), &paths)
+	if err != nil {
+		return nil, err
+	}
+	return CompilePaths(paths)
+}
+
+// WriteHeader writes the magic and h in "file" wire format.
+func WriteHeader(w io.Writer, h *pb.StepHeader) error {
+	_, err := io.WriteString(w, WireMagic)
+	if err != nil {
+		return fmt.Errorf("magic: %w", err)
+	}
+	return EncodeStep(w, &pb.Step{Step: &pb.Step_Header{Header: h}})
+}
+
+// ReadHeader reads the magic and the header in "file" wire format.
+func ReadHeader(r io.Reader) (*pb.StepHeader, error) {
+	var magic [4]byte
+	_, err := io.ReadFull(r, magic[:])
+	if err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != WireMagic {
+		return nil, errors.New("invalid magic")
+	}
+	step, err := DecodeStep(r)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := step.Step.(*pb.Step_Header)
+	if !ok {
+		return nil, errors.New("first step is not a header")
+	}
+	return h.Header, nil
+}
@@ -0,0 +1,158 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// TrailerWriter writes to an underlying writer, keeping the checksum of what was written for a trailer.
+type TrailerWriter struct {
+	w   io.Writer
+	sum hash.Hash
+}
+
+// NewTrailerWriter returns a TrailerWriter writing to w.
+func NewTrailerWriter(w io.Writer) *TrailerWriter {
+	return &TrailerWriter{w: w, sum: sha256.New()}
+}
+
+func (t *TrailerWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	t.sum.Write(p[:n])
+	return n, err
+}
+
+// Checksum returns the checksum of what was written so far.
+func (t *TrailerWriter) Checksum() []byte {
+	return t.sum.Sum(nil)
+}
+
+// WriteTrailer writes a trailer with the checksum of what was written so far. Nothing should be written after it.
+func (t *TrailerWriter) WriteTrailer() error {
+	return EncodeStep(t.w, &pb.Step{Step: &pb.Step_Trailer{Trailer: &pb.StepTrailer{Checksum: t.Checksum()}}})
+}
+
+// Chain is the state of a full snapshot, after applying incremental snapshots to it in order.
+type Chain struct {
+	// Header is the header of the last snapshot applied, with parent cleared.
+	Header *pb.StepHeader
+	// Files are by their path relative to the root, as an absolute path (e.g. /etc/passwd).
+	Files map[string]FileInfo2
+	// Checksum is the checksum of the last snapshot applied, which the next must have as its parent.
+	Checksum []byte
+}
+
+// NewChain returns an empty Chain, to apply a full snapshot to first.
+func NewChain() *Chain {
+	return &Chain{Files: map[string]FileInfo2{}}
+}
+
+// Apply applies the snapshot in r to c.
+// The first snapshot must be full, and the rest incremental to the one before.
+func (c *Chain) Apply(r io.Reader) error {
+	root := "/"
+	checksum, err := Decode(r, Handlers{
+		Header: func(h *pb.StepHeader) error {
+			switch {
+			case c.Checksum == nil && len(h.Parent) != 0:
+				return fmt.Errorf("incremental to %x, but its parent was not applied first", h.Parent)
+			case c.Checksum != nil && len(h.Parent) == 0:
+				return errors.New("full snapshot, not incremental to the one before")
+			case c.Checksum != nil && !bytes.Equal(h.Parent, c.Checksum):
+				return fmt.Errorf("incremental to %x, not the one before (%x)", h.Parent, c.Checksum)
+			}
+			root = h.Root
+			c.Header = proto.Clone(h).(*pb.StepHeader)
+			c.Header.Parent = nil
+			return nil
+		},
+		File: func(fi FileInfo2) error {
+			c.Files[InRoot(root, filepath.Join(fi.Path, fi.Name))] = fi
+			return nil
+		},
+		Remove: func(path string) error {
+			rel := InRoot(root, path)
+			if fi, ok := c.Files[rel]; ok && fi.Mode.IsDir() {
+				prefix := strings.TrimSuffix(rel, "/") + "/"
+				for rel2 := range c.Files {
+					if strings.HasPrefix(rel2, prefix) {
+						delete(c.Files, rel2)
+					}
+				}
+			}
+			delete(c.Files, rel)
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if c.Header == nil {
+		return errors.New("no header")
+	}
+	c.Checksum = checksum
+	return nil
+}
+
+// FileInfos returns the files in c, under the root in Header.
+func (c *Chain) FileInfos() []FileInfo2 {
+	rels := make([]string, 0, len(c.Files))
+	for rel := range c.Files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	files := make([]FileInfo2, len(rels))
+	for i, rel := range rels {
+		files[i] = c.Files[rel]
+		// as decoded, so from / even under a relative root
+		files[i].Path = filepath.Join("/", c.Header.Root, filepath.Dir(rel))
+	}
+	return files
+}
+
+// Changes returns the header of the (full) snapshot in r, the files in it added or changed from c, and the paths (under its root, as decoded) in c removed in it.
+func (c *Chain) Changes(r io.Reader) (h *pb.StepHeader, changed []FileInfo2, removed []string, err error) {
+	seen := map[string]bool{}
+	_, err = Decode(r, Handlers{
+		Header: func(h2 *pb.StepHeader) error {
+			h = h2
+			return nil
+		},
+		File: func(fi FileInfo2) error {
+			root := "/"
+			if h != nil {
+				root = h.Root
+			}
+			rel := InRoot(root, filepath.Join(fi.Path, fi.Name))
+			seen[rel] = true
+			old, ok := c.Files[rel]
+			if !ok || !proto.Equal(old.StepFile(), fi.StepFile()) {
+				changed = append(changed, fi)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if h == nil {
+		return nil, nil, nil, errors.New("no header")
+	}
+	for rel := range c.Files {
+		if !seen[rel] {
+			removed = append(removed, filepath.Join("/", h.Root, rel))
+		}
+	}
+	sort.Strings(removed)
+	return h, changed, removed, nil
+}
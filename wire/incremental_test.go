@@ -0,0 +1,217 @@
+package wire
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"google.golang.org/protobuf/proto"
+)
+
+// writeSnapshot returns a snapshot of files and removals (both as decoded, under root) written with Writer, and its checksum.
+func writeSnapshot(t *testing.T, root string, parent []byte, files []FileInfo2, removed []string) ([]byte, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.WriteHeader(&pb.StepHeader{Version: HeaderVersion, Root: root, Parent: parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		err = w.Write(&files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range removed {
+		err = w.Remove(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum, err := Decode(bytes.NewReader(buf.Bytes()), Handlers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), checksum
+}
+
+// testTree returns files under root (as decoded), relative to it as paths such as /etc/passwd mapped to their content.
+func testTree(root string, tree map[string]string) []FileInfo2 {
+	dir := filepath.Join("/", root)
+	var files []FileInfo2
+	for rel, content := range tree {
+		fi := FileInfo2{Name: filepath.Base(rel), Path: filepath.Join(dir, filepath.Dir(rel)), Mtime: time.Unix(1700000000, 0)}
+		if strings.HasSuffix(content, "/") {
+			fi.Mode = fs.ModeDir | 0o755
+		} else {
+			fi.Mode = 0o644
+			fi.Size = uint64(len(content))
+			fi.Hash = []byte(content)
+		}
+		files = append(files, fi)
+	}
+	return files
+}
+
+// chainFiles returns the files in c by path, with their steps as text for comparison.
+func chainFiles(c *Chain) map[string]string {
+	files := map[string]string{}
+	for rel, fi := range c.Files {
+		files[rel] = fi.StepFile().String()
+	}
+	return files
+}
+
+func TestChainApply(t *testing.T) {
+	root := "td"
+	v1 := testTree(root, map[string]string{"/etc": "/", "/etc/passwd": "a", "/etc/ssl": "/", "/etc/ssl/cert": "c", "/etcetera": "e"})
+	full, checksum := writeSnapshot(t, root, nil, v1, nil)
+
+	c := NewChain()
+	inc, _ := writeSnapshot(t, root, checksum, nil, nil)
+	if err := c.Apply(bytes.NewReader(inc)); err == nil {
+		t.Error("incremental first: no error")
+	}
+	var noHeader bytes.Buffer
+	w := NewWriter(&noHeader)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(&noHeader); err == nil {
+		t.Error("no header: no error")
+	}
+
+	c = NewChain()
+	if err := c.Apply(bytes.NewReader(full)); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Files) != 5 || !bytes.Equal(c.Checksum, checksum) || c.Header.Root != root {
+		t.Fatalf("after full: %d files, checksum %x, header %v", len(c.Files), c.Checksum, c.Header)
+	}
+	if err := c.Apply(bytes.NewReader(full)); err == nil {
+		t.Error("full applied as incremental: no error")
+	}
+	wrong, _ := writeSnapshot(t, root, []byte("wrong"), nil, nil)
+	if err := c.Apply(bytes.NewReader(wrong)); err == nil {
+		t.Error("wrong parent: no error")
+	}
+	if len(c.Files) != 5 || !bytes.Equal(c.Checksum, checksum) {
+		t.Fatalf("changed by failed applies: %d files, checksum %x", len(c.Files), c.Checksum)
+	}
+
+	// removing a directory removes what is in it, but not siblings sharing its prefix
+	inc, checksum2 := writeSnapshot(t, root, checksum, testTree(root, map[string]string{"/etc/passwd": "b"}), []string{"/td/etc/ssl"})
+	if err := c.Apply(bytes.NewReader(inc)); err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for rel := range c.Files {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	if strings.Join(rels, " ") != "/etc /etc/passwd /etcetera" {
+		t.Errorf("files %q", rels)
+	}
+	if string(c.Files["/etc/passwd"].Hash) != "b" || !bytes.Equal(c.Checksum, checksum2) || len(c.Header.Parent) != 0 {
+		t.Errorf("after incremental: %+v, checksum %x, header %v", c.Files["/etc/passwd"], c.Checksum, c.Header)
+	}
+	if err := c.Apply(bytes.NewReader(inc)); err == nil {
+		t.Error("incremental applied twice: no error")
+	}
+}
+
+// TestChainRoundTrip makes an incremental snapshot with Changes, applies it, and flattens the chain, which should be the same as the full snapshot.
+func TestChainRoundTrip(t *testing.T) {
+	for _, root := range []string{"/", "/abs/td", "td", "./td"} {
+		v1 := map[string]string{"/etc": "/", "/etc/passwd": "a", "/etc/ssl": "/", "/etc/ssl/cert": "c", "/bin": "/", "/bin/sh": "sh"}
+		v2 := map[string]string{"/etc": "/", "/etc/passwd": "b", "/bin": "/", "/bin/sh": "sh", "/bin/ls": "ls", "/new": "/", "/new/f": "f"}
+		full1, checksum := writeSnapshot(t, root, nil, testTree(root, v1), nil)
+		full2, _ := writeSnapshot(t, root, nil, testTree(root, v2), nil)
+
+		c := NewChain()
+		if err := c.Apply(bytes.NewReader(full1)); err != nil {
+			t.Fatal(err)
+		}
+		h, changed, removed, err := c.Changes(bytes.NewReader(full2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var changedRels []string
+		for _, fi := range changed {
+			changedRels = append(changedRels, InRoot(root, filepath.Join(fi.Path, fi.Name)))
+		}
+		sort.Strings(changedRels)
+		if strings.Join(changedRels, " ") != "/bin/ls /etc/passwd /new /new/f" {
+			t.Errorf("%s: changed %q", root, changedRels)
+		}
+		dir := filepath.Join("/", root)
+		if strings.Join(removed, " ") != filepath.Join(dir, "etc/ssl")+" "+filepath.Join(dir, "etc/ssl/cert") {
+			t.Errorf("%s: removed %q", root, removed)
+		}
+
+		var inc bytes.Buffer
+		h = proto.Clone(h).(*pb.StepHeader)
+		h.Parent = c.Checksum
+		tw := NewTrailerWriter(&inc)
+		err = EncodeIncremental(tw, h, changed, removed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tw.WriteTrailer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Apply(&inc); err != nil {
+			t.Fatalf("%s: apply incremental: %s", root, err)
+		}
+		if bytes.Equal(c.Checksum, checksum) {
+			t.Errorf("%s: checksum not updated", root)
+		}
+
+		want := NewChain()
+		if err := want.Apply(bytes.NewReader(full2)); err != nil {
+			t.Fatal(err)
+		}
+		// flatten, as hino-flatten does
+		var flat bytes.Buffer
+		tw = NewTrailerWriter(&flat)
+		err = EncodeFiles(tw, c.Header, c.FileInfos())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tw.WriteTrailer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		flattened := NewChain()
+		if err := flattened.Apply(&flat); err != nil {
+			t.Fatalf("%s: apply flattened: %s", root, err)
+		}
+		for name, got := range map[string]*Chain{"chain": c, "flattened": flattened} {
+			gotFiles, wantFiles := chainFiles(got), chainFiles(want)
+			if len(gotFiles) != len(wantFiles) {
+				t.Errorf("%s: %s: %d files, want %d", root, name, len(gotFiles), len(wantFiles))
+			}
+			for rel, s := range wantFiles {
+				if gotFiles[rel] != s {
+					t.Errorf("%s: %s: %s is %s, want %s", root, name, rel, gotFiles[rel], s)
+				}
+			}
+		}
+		for _, fi := range c.FileInfos() {
+			if !strings.HasPrefix(fi.Path, dir) {
+				t.Errorf("%s: %s is not under %s as decoded", root, fi.Path, dir)
+			}
+		}
+	}
+}
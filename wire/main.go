@@ -9,8 +9,14 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/constraints"
+
+	"github.com/nyiyui/opt/hinomori/pkgdb"
+	"github.com/nyiyui/opt/hinomori/store"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
 )
 
 type FileInfo struct {
@@ -30,6 +36,26 @@ type FileInfo2 struct {
 	Hash  []byte
 	Owner uint32
 	Group uint32
+
+	HashErr string
+	// Mtime is zero if not collected.
+	Mtime  time.Time
+	Link   string
+	Xattrs map[string][]byte
+	// MountPoint is whether this is a mount point, and FsType is its filesystem type, if known.
+	MountPoint bool
+	FsType     string
+
+	Package        string
+	PackageVersion string
+	PackageManager string
+	PackageStatus  pb.PackageStatus
+
+	// Embedded is whether Content is the content of this file.
+	Embedded bool
+	Content  []byte
+	// StoreHash is the SHA-256 of the content in a blob store, if copied to one.
+	StoreHash []byte
 }
 
 func (f *FileInfo2) String() string {
@@ -182,7 +208,9 @@ func decodeWireFile(r io.Reader, fi *FileInfo2) error {
 }
 
 type qItem struct {
-	First     bool
+	Depth     uint32
+	Rules     *rules.Set
+	Dev       uint64
 	End       bool
 	Down      string
 	DownCount int
@@ -223,15 +251,40 @@ func common[T comparable](a, b []T) int {
 }
 
 type Walker struct {
-	blockedPaths []*regexp.Regexp
-	hashPaths    []*regexp.Regexp
-	hashAll      bool
+	blockedPaths  []*regexp.Regexp
+	hashPaths     []*regexp.Regexp
+	hashAll       bool
+	hashAlgorithm string
+	metadata      map[string]bool
+	maxHashSize   uint64
+	maxDepth      uint32
+	profile       string
+	blockRules    rules.Rules
+	hashRules     rules.Rules
+	hinoignore    bool
+	oneFileSystem bool
+	skipFsTypes   []string
+	packages      *pkgdb.DB
+
+	contentRules   rules.Rules
+	maxContentSize uint64
+	store          *store.Store
+}
+
+// Metadata are the names of optional metadata a Walker can collect.
+var Metadata = []string{
+	// mtime is the modification time.
+	"mtime",
+	// links includes symbolic links and their targets.
+	"links",
+	// xattrs are extended attributes.
+	"xattrs",
 }
 
 var defaultBlockedPaths = []*regexp.Regexp{
-	regexp.MustCompile("^/dev.*"),
+	regexp.MustCompile("^/dev(/|$)"),
 	// /dev/console, /dev/stdin, /dev/u?random, etc
-	regexp.MustCompile("^/proc.*"),
+	regexp.MustCompile("^/proc(/|$)"),
 }
 
 // NewWalker returns a new Walker with sane default.
@@ -253,6 +306,90 @@ func (w *Walker) Hash(paths []*regexp.Regexp) {
 	w.hashPaths = append(w.hashPaths, paths...)
 }
 
+// BlockRules adds gitignore-style rules (relative to the root) for paths to block.
+func (w *Walker) BlockRules(rs rules.Rules) {
+	w.blockRules = append(w.blockRules, rs...)
+}
+
+// HashRules adds gitignore-style rules (relative to the root) for paths to hash.
+func (w *Walker) HashRules(rs rules.Rules) {
+	w.hashRules = append(w.hashRules, rs...)
+}
+
+// ContentRules adds gitignore-style rules (relative to the root) for regular files whose content is embedded.
+func (w *Walker) ContentRules(rs rules.Rules) {
+	w.contentRules = append(w.contentRules, rs...)
+}
+
+// DefaultMaxContentSize is the size above which content is not embedded, unless set by MaxContentSize.
+const DefaultMaxContentSize = 64 << 10
+
+// MaxContentSize sets the size above which content is not embedded. 0 means DefaultMaxContentSize.
+func (w *Walker) MaxContentSize(size uint64) {
+	w.maxContentSize = size
+}
+
+func (w *Walker) contentLimit() uint64 {
+	if w.maxContentSize == 0 {
+		return DefaultMaxContentSize
+	}
+	return w.maxContentSize
+}
+
+// Store sets the blob store the content of hashed files is copied to. nil means none.
+func (w *Walker) Store(s *store.Store) {
+	w.store = s
+}
+
+// Hinoignore sets whether HinoignoreName files add block rules for their directory.
+func (w *Walker) Hinoignore(hinoignore bool) {
+	w.hinoignore = hinoignore
+}
+
+// HashAlgorithm sets the algorithm used for hashing. See HashAlgorithms.
+func (w *Walker) HashAlgorithm(name string) error {
+	if _, ok := HashAlgorithms[name]; !ok && name != "" {
+		return fmt.Errorf("unknown hash algorithm %s", name)
+	}
+	w.hashAlgorithm = name
+	return nil
+}
+
+// Metadata adds names to the optional metadata collected. See Metadata.
+func (w *Walker) Metadata(names []string) error {
+	if w.metadata == nil {
+		w.metadata = map[string]bool{}
+	}
+	for _, name := range names {
+		known := false
+		for _, name2 := range Metadata {
+			if name == name2 {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown metadata %s", name)
+		}
+		w.metadata[name] = true
+	}
+	return nil
+}
+
+// MaxHashSize sets the size above which files are not hashed. 0 means no limit.
+func (w *Walker) MaxHashSize(size uint64) {
+	w.maxHashSize = size
+}
+
+// MaxDepth sets the depth below which directories are not walked. 0 means no limit.
+func (w *Walker) MaxDepth(depth uint32) {
+	w.maxDepth = depth
+}
+
+// Profile sets the name of the profile recorded in the header.
+func (w *Walker) Profile(name string) {
+	w.profile = name
+}
+
 func (w *Walker) isBlocked(path string) bool {
 	for _, blocked := range w.blockedPaths {
 		if blocked.Match([]byte(path)) {
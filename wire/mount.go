@@ -0,0 +1,128 @@
+package wire
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// MountinfoPath is the path to the mount table of the walking process.
+const MountinfoPath = "/proc/self/mountinfo"
+
+// ReadMountinfo parses a mountinfo file (see proc(5)) into a map of mount points to filesystem types.
+func ReadMountinfo(r io.Reader) (map[string]string, error) {
+	mounts := map[string]string{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		// the separator is after 6 fields (ID to mount options) and any optional fields
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep == -1 || sep+1 >= len(fields) {
+			return nil, fmt.Errorf("invalid mountinfo line %q", s.Text())
+		}
+		mounts[unescapeMountinfo(fields[4])] = fields[sep+1]
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// unescapeMountinfo unescapes octal escapes such as \040 for a space.
+func unescapeMountinfo(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	b := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			n, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			if err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func readMountinfoFile() (map[string]string, error) {
+	f, err := os.Open(MountinfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMountinfo(f)
+}
+
+// OneFileSystem sets whether to not descend into directories on other filesystems than the root.
+func (w *Walker) OneFileSystem(oneFileSystem bool) {
+	w.oneFileSystem = oneFileSystem
+}
+
+// SkipFsTypes adds filesystem types (as in mountinfo, e.g. tmpfs) not to descend into.
+// Types are matched using path.Match, so fuse.* matches all FUSE filesystems.
+func (w *Walker) SkipFsTypes(types []string) {
+	w.skipFsTypes = append(w.skipFsTypes, types...)
+}
+
+func (w *Walker) isSkippedFsType(fsType string) bool {
+	for _, pattern := range w.skipFsTypes {
+		if ok, _ := path.Match(pattern, fsType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type mountRes struct {
+	Dev        uint64
+	MountPoint bool
+	FsType     string
+	Descend    bool
+}
+
+func statDev(info fs.FileInfo) (uint64, bool) {
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(sys.Dev), true
+}
+
+// mount returns whether the directory name (in a directory on parentDev) is a mount point, and whether to descend into it.
+func (w *Walker) mount(name string, entry fs.DirEntry, parentDev, rootDev uint64, mounts map[string]string) mountRes {
+	res := mountRes{Dev: parentDev, Descend: true}
+	info, err := entry.Info()
+	if err == nil {
+		if dev, ok := statDev(info); ok {
+			res.Dev = dev
+		}
+	}
+	fsType, ok := mounts[name]
+	if ok || res.Dev != parentDev {
+		res.MountPoint = true
+		res.FsType = fsType
+	}
+	if w.oneFileSystem && res.Dev != rootDev {
+		res.Descend = false
+	}
+	if res.MountPoint && w.isSkippedFsType(res.FsType) {
+		res.Descend = false
+	}
+	return res
+}
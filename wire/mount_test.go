@@ -0,0 +1,83 @@
+package wire
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadMountinfo(t *testing.T) {
+	f, err := os.Open("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	mounts, err := ReadMountinfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/":              "ext4",
+		"/proc":          "proc",
+		"/sys":           "sysfs",
+		"/sys/fs/cgroup": "cgroup2",
+		"/dev":           "devtmpfs",
+		"/dev/pts":       "devpts",
+		"/run":           "tmpfs",
+		"/boot/efi":      "vfat",
+		// with optional fields before the separator
+		"/mnt/My Drive":            "fuse.rclone",
+		"/mnt/tab\tand\nnewline\\": "tmpfs",
+		"/srv/bind":                "ext4",
+		// the last mount on top wins
+		"/tmp": "tmpfs",
+		// not a valid escape
+		`/mnt/bad\09`: "tmpfs",
+	}
+	if len(mounts) != len(want) {
+		t.Errorf("%d mounts, want %d: %q", len(mounts), len(want), mounts)
+	}
+	for mountPoint, fsType := range want {
+		if mounts[mountPoint] != fsType {
+			t.Errorf("%q: %q, want %q", mountPoint, mounts[mountPoint], fsType)
+		}
+	}
+
+	for _, line := range []string{
+		"36 22 0:51 / /x rw,relatime",
+		"36 22 0:51 / /x rw,relatime -",
+		"36 22 0:51 / - tmpfs",
+		"36",
+	} {
+		_, err := ReadMountinfo(strings.NewReader(line + "\n"))
+		if err == nil {
+			t.Errorf("%q: no error", line)
+		}
+	}
+	mounts, err = ReadMountinfo(strings.NewReader(""))
+	if err != nil || len(mounts) != 0 {
+		t.Errorf("empty: %q, %v", mounts, err)
+	}
+}
+
+func TestUnescapeMountinfo(t *testing.T) {
+	for _, c := range []struct {
+		in, want string
+	}{
+		{"/plain", "/plain"},
+		{`/a\040b`, "/a b"},
+		{`\040`, " "},
+		{`/a\040\040b\040`, "/a  b "},
+		{`/back\134slash`, `/back\slash`},
+		{`/a\011b\012c`, "/a\tb\nc"},
+		// not escapes
+		{`/a\04`, `/a\04`},
+		{`/a\999`, `/a\999`},
+		{`/a\400`, `/a\400`},
+		{`/a\`, `/a\`},
+	} {
+		if got := unescapeMountinfo(c.in); got != c.want {
+			t.Errorf("%q: %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package wire
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/mtree"
+)
+
+// mtreeDigestKeywords maps names in HashAlgorithms to mtree digest keywords.
+var mtreeDigestKeywords = map[string]string{
+	"md5":    "md5digest",
+	"sha1":   "sha1digest",
+	"sha256": "sha256digest",
+}
+
+// mtreeDigestAliases are alternative names for mtree digest keywords.
+var mtreeDigestAliases = map[string]string{
+	"md5digest":    "md5",
+	"sha1digest":   "sha1",
+	"sha256digest": "sha256",
+}
+
+// UnixMode returns the Unix mode bits (permissions, setuid, setgid, and sticky) of m.
+func UnixMode(m fs.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&fs.ModeSetuid != 0 {
+		mode |= 0o4000
+	}
+	if m&fs.ModeSetgid != 0 {
+		mode |= 0o2000
+	}
+	if m&fs.ModeSticky != 0 {
+		mode |= 0o1000
+	}
+	return mode
+}
+
+// FileModeFromUnix returns the fs.FileMode with the Unix mode bits in mode, and type typ.
+func FileModeFromUnix(mode uint32, typ fs.FileMode) fs.FileMode {
+	m := fs.FileMode(mode&0o777) | typ
+	if mode&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
+
+var mtreeTypes = []struct {
+	name string
+	typ  fs.FileMode
+}{
+	{"dir", fs.ModeDir},
+	{"link", fs.ModeSymlink},
+	{"block", fs.ModeDevice},
+	{"char", fs.ModeDevice | fs.ModeCharDevice},
+	{"fifo", fs.ModeNamedPipe},
+	{"socket", fs.ModeSocket},
+	{"file", 0},
+}
+
+// FileType returns the type of m as named in mtree(5) (e.g. file, dir, or link).
+func FileType(m fs.FileMode) string {
+	for _, t := range mtreeTypes {
+		if m.Type() == t.typ {
+			return t.name
+		}
+	}
+	return "file"
+}
+
+// MtreeEntry returns fi as an entry with a path relative to root.
+// hashAlgorithm is the algorithm of fi.Hash; hashes without an mtree keyword (e.g. xxhash) are omitted.
+func MtreeEntry(root string, fi FileInfo2, hashAlgorithm string) (mtree.Entry, error) {
+	rel := strings.TrimPrefix(InRoot(root, filepath.Join(fi.Path, fi.Name)), "/")
+	if rel == "" {
+		rel = "."
+	}
+	e := mtree.Entry{
+		Path: filepath.ToSlash(rel),
+		Keywords: map[string]string{
+			"type": FileType(fi.Mode),
+			"mode": fmt.Sprintf("%04o", UnixMode(fi.Mode)),
+			"uid":  strconv.FormatUint(uint64(fi.Owner), 10),
+			"gid":  strconv.FormatUint(uint64(fi.Group), 10),
+		},
+	}
+	if fi.Mode.IsRegular() {
+		e.Keywords["size"] = strconv.FormatUint(fi.Size, 10)
+	}
+	if fi.Mode&fs.ModeSymlink != 0 {
+		e.Keywords["link"] = fi.Link
+	}
+	if !fi.Mtime.IsZero() {
+		e.Keywords["time"] = fmt.Sprintf("%d.%09d", fi.Mtime.Unix(), fi.Mtime.Nanosecond())
+	}
+	if keyword, ok := mtreeDigestKeywords[hashAlgorithm]; ok && len(fi.Hash) != 0 {
+		e.Keywords[keyword] = hex.EncodeToString(fi.Hash)
+	}
+	return e, nil
+}
+
+// FileInfo2FromMtree returns e (relative to root) as a FileInfo2.
+// The digest for hashAlgorithm (e.g. sha256 for sha256digest) is used as the hash.
+func FileInfo2FromMtree(root string, e mtree.Entry, hashAlgorithm string) (FileInfo2, error) {
+	p := path.Join(filepath.ToSlash(root), e.Path)
+	fi := FileInfo2{
+		Name: path.Base(p),
+		Path: path.Dir(p),
+	}
+	var typ fs.FileMode
+	if v, ok := e.Get("type"); ok {
+		found := false
+		for _, t := range mtreeTypes {
+			if v == t.name {
+				typ = t.typ
+				found = true
+			}
+		}
+		if !found {
+			return fi, fmt.Errorf("%s: unknown type %s", e.Path, v)
+		}
+	}
+	if v, ok := e.Get("mode"); ok {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			return fi, fmt.Errorf("%s: mode: %w", e.Path, err)
+		}
+		fi.Mode = FileModeFromUnix(uint32(mode), typ)
+	} else {
+		fi.Mode = typ
+	}
+	for _, k := range []struct {
+		name string
+		dest *uint32
+	}{{"uid", &fi.Owner}, {"gid", &fi.Group}} {
+		if v, ok := e.Get(k.name); ok {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return fi, fmt.Errorf("%s: %s: %w", e.Path, k.name, err)
+			}
+			*k.dest = uint32(n)
+		}
+	}
+	if v, ok := e.Get("size"); ok {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return fi, fmt.Errorf("%s: size: %w", e.Path, err)
+		}
+		fi.Size = n
+	}
+	if v, ok := e.Get("link"); ok {
+		fi.Link = v
+		if _, ok := e.Get("size"); !ok {
+			// as lstat(2) reports it
+			fi.Size = uint64(len(v))
+		}
+	}
+	if v, ok := e.Get("time"); ok {
+		sec, nsec, _ := strings.Cut(v, ".")
+		s, err := strconv.ParseInt(sec, 10, 64)
+		if err != nil {
+			return fi, fmt.Errorf("%s: time: %w", e.Path, err)
+		}
+		var ns int64
+		if nsec != "" {
+			// nanoseconds are an integer; libarchive does not zero-pad them
+			ns, err = strconv.ParseInt(nsec, 10, 64)
+			if err != nil {
+				return fi, fmt.Errorf("%s: time: %w", e.Path, err)
+			}
+		}
+		fi.Mtime = time.Unix(s, ns)
+	}
+	for k, v := range e.Keywords {
+		if alias, ok := mtreeDigestAliases[k]; ok && alias == hashAlgorithm {
+			digest, err := hex.DecodeString(v)
+			if err != nil {
+				return fi, fmt.Errorf("%s: %s: %w", e.Path, k, err)
+			}
+			fi.Hash = digest
+		}
+	}
+	return fi, nil
+}
@@ -0,0 +1,44 @@
+package wire
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMtreeEntry(t *testing.T) {
+	mtime := time.Unix(1700000000, 5)
+	for _, c := range []struct {
+		root, dir, want string
+	}{
+		{"/", "/etc", "etc/passwd"},
+		{"/abs/td", "/abs/td/etc", "etc/passwd"},
+		// paths decoded from a walk of a relative root start from /
+		{"td", "/td/etc", "etc/passwd"},
+		{"./td", "/td/etc", "etc/passwd"},
+		{"./td", "/td", "passwd"},
+	} {
+		fi := FileInfo2{Mode: 0o644, Size: 3, Name: "passwd", Path: c.dir, Owner: 1, Group: 2, Mtime: mtime, Hash: []byte{0xab}}
+		e, err := MtreeEntry(c.root, fi, "sha256")
+		if err != nil {
+			t.Errorf("%s %s: %s", c.root, c.dir, err)
+			continue
+		}
+		if e.Path != c.want {
+			t.Errorf("%s %s: path %q, want %q", c.root, c.dir, e.Path, c.want)
+		}
+		for k, v := range map[string]string{"type": "file", "mode": "0644", "uid": "1", "gid": "2", "size": "3", "time": "1700000000.000000005", "sha256digest": "ab"} {
+			if got, _ := e.Get(k); got != v {
+				t.Errorf("%s %s: %s=%q, want %q", c.root, c.dir, k, got, v)
+			}
+		}
+
+		fi2, err := FileInfo2FromMtree("/", e, "sha256")
+		if err != nil {
+			t.Errorf("%s %s: back: %s", c.root, c.dir, err)
+			continue
+		}
+		if fi2.Mode != fi.Mode || fi2.Size != fi.Size || fi2.Owner != fi.Owner || fi2.Group != fi.Group || !fi2.Mtime.Equal(mtime) || string(fi2.Hash) != string(fi.Hash) {
+			t.Errorf("%s %s: back %+v, want %+v", c.root, c.dir, fi2, fi)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Names are the names of UIDs and GIDs in a tree.
+type Names struct {
+	Users  map[uint32]string
+	Groups map[uint32]string
+}
+
+// ReadNames reads the names of UIDs and GIDs from etc/passwd and etc/group in root (not the host's, unless root is /).
+// Missing files result in no names.
+func ReadNames(root string) (*Names, error) {
+	users, err := ReadIDFile(filepath.Join(root, "etc/passwd"))
+	if err != nil {
+		return nil, err
+	}
+	groups, err := ReadIDFile(filepath.Join(root, "etc/group"))
+	if err != nil {
+		return nil, err
+	}
+	return &Names{Users: users, Groups: groups}, nil
+}
+
+// ReadIDFile reads names by ID (the first and third fields) from a passwd- or group-style file.
+// A missing file results in no names.
+// The first name of an ID is used.
+func ReadIDFile(path string) (map[uint32]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names := map[uint32]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			// comments and NIS entries
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 || fields[0] == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		if _, ok := names[uint32(id)]; !ok {
+			names[uint32(id)] = fields[0]
+		}
+	}
+	return names, s.Err()
+}
+
+// NamesFromHeader returns the names recorded in h, or nil if there are none.
+func NamesFromHeader(h *pb.StepHeader) *Names {
+	if h == nil || (len(h.Users) == 0 && len(h.Groups) == 0) {
+		return nil
+	}
+	return &Names{Users: h.Users, Groups: h.Groups}
+}
+
+// User returns the name of uid, or uid in decimal if it has no name.
+func (n *Names) User(uid uint32) string {
+	if n != nil {
+		if name, ok := n.Users[uid]; ok {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(uid), 10)
+}
+
+// Group returns the name of gid, or gid in decimal if it has no name.
+func (n *Names) Group(gid uint32) string {
+	if n != nil {
+		if name, ok := n.Groups[gid]; ok {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(gid), 10)
+}
+
+// UserID returns uid, with its name if any (e.g. 0(root)).
+func (n *Names) UserID(uid uint32) string {
+	if n != nil {
+		if name, ok := n.Users[uid]; ok {
+			return fmt.Sprintf("%d(%s)", uid, name)
+		}
+	}
+	return strconv.FormatUint(uint64(uid), 10)
+}
+
+// GroupID returns gid, with its name if any (e.g. 42(shadow)).
+func (n *Names) GroupID(gid uint32) string {
+	if n != nil {
+		if name, ok := n.Groups[gid]; ok {
+			return fmt.Sprintf("%d(%s)", gid, name)
+		}
+	}
+	return strconv.FormatUint(uint64(gid), 10)
+}
@@ -0,0 +1,62 @@
+package wire
+
+import (
+	"bytes"
+	"io/fs"
+
+	"github.com/nyiyui/opt/hinomori/pkgdb"
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Packages sets the package database used to attribute files to packages.
+// See pkgdb.Load.
+func (w *Walker) Packages(db *pkgdb.DB) {
+	w.packages = db
+}
+
+// PackageStatusName returns the name of s (e.g. "modified"), or "" if unspecified.
+func PackageStatusName(s pb.PackageStatus) string {
+	switch s {
+	case pb.PackageStatus_PACKAGE_STATUS_OWNED:
+		return "owned"
+	case pb.PackageStatus_PACKAGE_STATUS_VERIFIED:
+		return "verified"
+	case pb.PackageStatus_PACKAGE_STATUS_MODIFIED:
+		return "modified"
+	case pb.PackageStatus_PACKAGE_STATUS_UNOWNED:
+		return "unowned"
+	default:
+		return ""
+	}
+}
+
+// attribute returns the package owning name, and whether it was modified.
+// hash is the hash already made by w, if any.
+func (w *Walker) attribute(root, name string, info fs.FileInfo, hash []byte) (pkgdb.Owner, pb.PackageStatus, error) {
+	o, ok := w.packages.Owner(InRoot(root, name))
+	if !ok {
+		if info.IsDir() {
+			return o, pb.PackageStatus_PACKAGE_STATUS_UNSPECIFIED, nil
+		}
+		return o, pb.PackageStatus_PACKAGE_STATUS_UNOWNED, nil
+	}
+	if len(o.Digest) == 0 || !info.Mode().IsRegular() {
+		return o, pb.PackageStatus_PACKAGE_STATUS_OWNED, nil
+	}
+	algorithm := w.hashAlgorithm
+	if algorithm == "" {
+		algorithm = "xxhash"
+	}
+	digest := hash
+	if o.DigestAlgorithm != algorithm || hash == nil {
+		var err error
+		digest, err = HashFile(name, o.DigestAlgorithm)
+		if err != nil {
+			return o, pb.PackageStatus_PACKAGE_STATUS_OWNED, err
+		}
+	}
+	if !bytes.Equal(digest, o.Digest) {
+		return o, pb.PackageStatus_PACKAGE_STATUS_MODIFIED, nil
+	}
+	return o, pb.PackageStatus_PACKAGE_STATUS_VERIFIED, nil
+}
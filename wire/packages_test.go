@@ -0,0 +1,22 @@
+package wire
+
+import (
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+func TestPackageStatusName(t *testing.T) {
+	names := map[string]bool{}
+	for v := range pb.PackageStatus_name {
+		s := pb.PackageStatus(v)
+		name := PackageStatusName(s)
+		if (name == "") != (s == pb.PackageStatus_PACKAGE_STATUS_UNSPECIFIED) {
+			t.Errorf("%s: name %q", s, name)
+		}
+		if names[name] {
+			t.Errorf("%s: duplicate name %q", s, name)
+		}
+		names[name] = true
+	}
+}
@@ -0,0 +1,11 @@
+package wire
+
+import "path/filepath"
+
+// InRoot returns name (inside root) as an absolute path inside root (e.g. /etc/passwd for /mnt/etc/passwd in /mnt).
+// A relative root or name is taken from /, as paths decoded from a walk of a relative root are.
+func InRoot(root, name string) string {
+	// both are absolute, so this cannot fail
+	rel, _ := filepath.Rel(filepath.Join("/", root), filepath.Join("/", name))
+	return filepath.Join("/", rel)
+}
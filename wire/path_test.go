@@ -0,0 +1,29 @@
+package wire
+
+import "testing"
+
+func TestInRoot(t *testing.T) {
+	for _, c := range []struct {
+		root, name, want string
+	}{
+		{"/", "/etc/passwd", "/etc/passwd"},
+		{"/", "/", "/"},
+		{"/", "etc", "/etc"},
+		{"/mnt", "/mnt/etc/passwd", "/etc/passwd"},
+		{"/mnt/", "/mnt", "/"},
+		{"/abs/td", "/abs/td/etc/passwd", "/etc/passwd"},
+		{"/abs/td", "/abs/td", "/"},
+		{"/abs/td/", "/abs/td/etc/", "/etc"},
+		{"td", "/td/etc/passwd", "/etc/passwd"},
+		{"td", "td/etc", "/etc"},
+		{"td", "/td", "/"},
+		{"./td", "/td/etc/passwd", "/etc/passwd"},
+		{"./td", "td/etc", "/etc"},
+		{"./td", "./td", "/"},
+	} {
+		got := InRoot(c.root, c.name)
+		if got != c.want {
+			t.Errorf("InRoot(%q, %q) = %q, want %q", c.root, c.name, got, c.want)
+		}
+	}
+}
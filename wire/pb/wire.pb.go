@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.26.0
+// 	protoc-gen-go v1.28.1
 // 	protoc        v3.21.7
 // source: wire.proto
 
@@ -20,6 +20,66 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type PackageStatus int32
+
+const (
+	// PACKAGE_STATUS_UNSPECIFIED means package databases were not read, or this is an unowned directory.
+	PackageStatus_PACKAGE_STATUS_UNSPECIFIED PackageStatus = 0
+	// PACKAGE_STATUS_OWNED means this is owned by a package, but its digest was not checked.
+	PackageStatus_PACKAGE_STATUS_OWNED PackageStatus = 1
+	// PACKAGE_STATUS_VERIFIED means this is owned by a package and its digest matches.
+	PackageStatus_PACKAGE_STATUS_VERIFIED PackageStatus = 2
+	// PACKAGE_STATUS_MODIFIED means this is owned by a package but its digest does not match.
+	PackageStatus_PACKAGE_STATUS_MODIFIED PackageStatus = 3
+	// PACKAGE_STATUS_UNOWNED means this is not owned by any package.
+	PackageStatus_PACKAGE_STATUS_UNOWNED PackageStatus = 4
+)
+
+// Enum value maps for PackageStatus.
+var (
+	PackageStatus_name = map[int32]string{
+		0: "PACKAGE_STATUS_UNSPECIFIED",
+		1: "PACKAGE_STATUS_OWNED",
+		2: "PACKAGE_STATUS_VERIFIED",
+		3: "PACKAGE_STATUS_MODIFIED",
+		4: "PACKAGE_STATUS_UNOWNED",
+	}
+	PackageStatus_value = map[string]int32{
+		"PACKAGE_STATUS_UNSPECIFIED": 0,
+		"PACKAGE_STATUS_OWNED":       1,
+		"PACKAGE_STATUS_VERIFIED":    2,
+		"PACKAGE_STATUS_MODIFIED":    3,
+		"PACKAGE_STATUS_UNOWNED":     4,
+	}
+)
+
+func (x PackageStatus) Enum() *PackageStatus {
+	p := new(PackageStatus)
+	*p = x
+	return p
+}
+
+func (x PackageStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (PackageStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_wire_proto_enumTypes[0].Descriptor()
+}
+
+func (PackageStatus) Type() protoreflect.EnumType {
+	return &file_wire_proto_enumTypes[0]
+}
+
+func (x PackageStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use PackageStatus.Descriptor instead.
+func (PackageStatus) EnumDescriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{0}
+}
+
 type Step struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -29,6 +89,10 @@ type Step struct {
 	//	*Step_File
 	//	*Step_Up
 	//	*Step_Down
+	//	*Step_Header
+	//	*Step_Blob
+	//	*Step_Trailer
+	//	*Step_Remove
 	Step isStep_Step `protobuf_oneof:"step"`
 }
 
@@ -92,6 +156,34 @@ func (x *Step) GetDown() *StepPathDown {
 	return nil
 }
 
+func (x *Step) GetHeader() *StepHeader {
+	if x, ok := x.GetStep().(*Step_Header); ok {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *Step) GetBlob() *StepBlob {
+	if x, ok := x.GetStep().(*Step_Blob); ok {
+		return x.Blob
+	}
+	return nil
+}
+
+func (x *Step) GetTrailer() *StepTrailer {
+	if x, ok := x.GetStep().(*Step_Trailer); ok {
+		return x.Trailer
+	}
+	return nil
+}
+
+func (x *Step) GetRemove() *StepRemove {
+	if x, ok := x.GetStep().(*Step_Remove); ok {
+		return x.Remove
+	}
+	return nil
+}
+
 type isStep_Step interface {
 	isStep_Step()
 }
@@ -108,12 +200,190 @@ type Step_Down struct {
 	Down *StepPathDown `protobuf:"bytes,3,opt,name=down,proto3,oneof"`
 }
 
+type Step_Header struct {
+	Header *StepHeader `protobuf:"bytes,4,opt,name=header,proto3,oneof"`
+}
+
+type Step_Blob struct {
+	Blob *StepBlob `protobuf:"bytes,5,opt,name=blob,proto3,oneof"`
+}
+
+type Step_Trailer struct {
+	Trailer *StepTrailer `protobuf:"bytes,6,opt,name=trailer,proto3,oneof"`
+}
+
+type Step_Remove struct {
+	Remove *StepRemove `protobuf:"bytes,7,opt,name=remove,proto3,oneof"`
+}
+
 func (*Step_File) isStep_Step() {}
 
 func (*Step_Up) isStep_Step() {}
 
 func (*Step_Down) isStep_Step() {}
 
+func (*Step_Header) isStep_Step() {}
+
+func (*Step_Blob) isStep_Step() {}
+
+func (*Step_Trailer) isStep_Step() {}
+
+func (*Step_Remove) isStep_Step() {}
+
+// StepTrailer is the last step, if any.
+type StepTrailer struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// checksum is the SHA-256 of the file (from the magic) before this step.
+	Checksum []byte `protobuf:"bytes,1,opt,name=checksum,proto3" json:"checksum,omitempty"`
+}
+
+func (x *StepTrailer) Reset() {
+	*x = StepTrailer{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_wire_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StepTrailer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepTrailer) ProtoMessage() {}
+
+func (x *StepTrailer) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepTrailer.ProtoReflect.Descriptor instead.
+func (*StepTrailer) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StepTrailer) GetChecksum() []byte {
+	if x != nil {
+		return x.Checksum
+	}
+	return nil
+}
+
+// StepRemove is the removal of the file named name in the current directory (and everything under it, if a directory), in incremental snapshots and watches.
+type StepRemove struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+}
+
+func (x *StepRemove) Reset() {
+	*x = StepRemove{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_wire_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StepRemove) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepRemove) ProtoMessage() {}
+
+func (x *StepRemove) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepRemove.ProtoReflect.Descriptor instead.
+func (*StepRemove) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StepRemove) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+// StepBlob is the content of files with embedded set and hash equal to its hash.
+// It comes before the first such file, and only once per hash.
+type StepBlob struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash    []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+}
+
+func (x *StepBlob) Reset() {
+	*x = StepBlob{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_wire_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StepBlob) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepBlob) ProtoMessage() {}
+
+func (x *StepBlob) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepBlob.ProtoReflect.Descriptor instead.
+func (*StepBlob) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StepBlob) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *StepBlob) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
 type StepFile struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -126,12 +396,31 @@ type StepFile struct {
 	Name    string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
 	Hash    []byte `protobuf:"bytes,4,opt,name=hash,proto3" json:"hash,omitempty"`
 	HashErr string `protobuf:"bytes,5,opt,name=hashErr,proto3" json:"hashErr,omitempty"`
+	// mtime is the modification time in nanoseconds since the Unix epoch, if collected.
+	Mtime int64 `protobuf:"varint,8,opt,name=mtime,proto3" json:"mtime,omitempty"`
+	// link is the target of a symbolic link, if collected.
+	Link string `protobuf:"bytes,9,opt,name=link,proto3" json:"link,omitempty"`
+	// xattrs are the extended attributes, if collected.
+	Xattrs map[string][]byte `protobuf:"bytes,10,rep,name=xattrs,proto3" json:"xattrs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// mountPoint is whether this is a mount point.
+	MountPoint bool `protobuf:"varint,11,opt,name=mountPoint,proto3" json:"mountPoint,omitempty"`
+	// fsType is the filesystem type of a mount point, if known.
+	FsType string `protobuf:"bytes,12,opt,name=fsType,proto3" json:"fsType,omitempty"`
+	// package, packageVersion, and packageManager describe the package owning this, if any.
+	Package        string        `protobuf:"bytes,13,opt,name=package,proto3" json:"package,omitempty"`
+	PackageVersion string        `protobuf:"bytes,14,opt,name=packageVersion,proto3" json:"packageVersion,omitempty"`
+	PackageManager string        `protobuf:"bytes,15,opt,name=packageManager,proto3" json:"packageManager,omitempty"`
+	PackageStatus  PackageStatus `protobuf:"varint,16,opt,name=packageStatus,proto3,enum=wire.PackageStatus" json:"packageStatus,omitempty"`
+	// embedded is whether the content of this is in a previous blob step with the same hash.
+	Embedded bool `protobuf:"varint,17,opt,name=embedded,proto3" json:"embedded,omitempty"`
+	// storeHash is the SHA-256 of the content, which was copied to a blob store, if any.
+	StoreHash []byte `protobuf:"bytes,18,opt,name=storeHash,proto3" json:"storeHash,omitempty"`
 }
 
 func (x *StepFile) Reset() {
 	*x = StepFile{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_wire_proto_msgTypes[1]
+		mi := &file_wire_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -144,7 +433,7 @@ func (x *StepFile) String() string {
 func (*StepFile) ProtoMessage() {}
 
 func (x *StepFile) ProtoReflect() protoreflect.Message {
-	mi := &file_wire_proto_msgTypes[1]
+	mi := &file_wire_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -157,7 +446,7 @@ func (x *StepFile) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StepFile.ProtoReflect.Descriptor instead.
 func (*StepFile) Descriptor() ([]byte, []int) {
-	return file_wire_proto_rawDescGZIP(), []int{1}
+	return file_wire_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *StepFile) GetMode() uint32 {
@@ -209,6 +498,83 @@ func (x *StepFile) GetHashErr() string {
 	return ""
 }
 
+func (x *StepFile) GetMtime() int64 {
+	if x != nil {
+		return x.Mtime
+	}
+	return 0
+}
+
+func (x *StepFile) GetLink() string {
+	if x != nil {
+		return x.Link
+	}
+	return ""
+}
+
+func (x *StepFile) GetXattrs() map[string][]byte {
+	if x != nil {
+		return x.Xattrs
+	}
+	return nil
+}
+
+func (x *StepFile) GetMountPoint() bool {
+	if x != nil {
+		return x.MountPoint
+	}
+	return false
+}
+
+func (x *StepFile) GetFsType() string {
+	if x != nil {
+		return x.FsType
+	}
+	return ""
+}
+
+func (x *StepFile) GetPackage() string {
+	if x != nil {
+		return x.Package
+	}
+	return ""
+}
+
+func (x *StepFile) GetPackageVersion() string {
+	if x != nil {
+		return x.PackageVersion
+	}
+	return ""
+}
+
+func (x *StepFile) GetPackageManager() string {
+	if x != nil {
+		return x.PackageManager
+	}
+	return ""
+}
+
+func (x *StepFile) GetPackageStatus() PackageStatus {
+	if x != nil {
+		return x.PackageStatus
+	}
+	return PackageStatus_PACKAGE_STATUS_UNSPECIFIED
+}
+
+func (x *StepFile) GetEmbedded() bool {
+	if x != nil {
+		return x.Embedded
+	}
+	return false
+}
+
+func (x *StepFile) GetStoreHash() []byte {
+	if x != nil {
+		return x.StoreHash
+	}
+	return nil
+}
+
 type StepPathUp struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -220,7 +586,7 @@ type StepPathUp struct {
 func (x *StepPathUp) Reset() {
 	*x = StepPathUp{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_wire_proto_msgTypes[2]
+		mi := &file_wire_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -233,7 +599,7 @@ func (x *StepPathUp) String() string {
 func (*StepPathUp) ProtoMessage() {}
 
 func (x *StepPathUp) ProtoReflect() protoreflect.Message {
-	mi := &file_wire_proto_msgTypes[2]
+	mi := &file_wire_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -246,7 +612,7 @@ func (x *StepPathUp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StepPathUp.ProtoReflect.Descriptor instead.
 func (*StepPathUp) Descriptor() ([]byte, []int) {
-	return file_wire_proto_rawDescGZIP(), []int{2}
+	return file_wire_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *StepPathUp) GetUp() uint32 {
@@ -267,7 +633,7 @@ type StepPathDown struct {
 func (x *StepPathDown) Reset() {
 	*x = StepPathDown{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_wire_proto_msgTypes[3]
+		mi := &file_wire_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -280,7 +646,7 @@ func (x *StepPathDown) String() string {
 func (*StepPathDown) ProtoMessage() {}
 
 func (x *StepPathDown) ProtoReflect() protoreflect.Message {
-	mi := &file_wire_proto_msgTypes[3]
+	mi := &file_wire_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -293,7 +659,7 @@ func (x *StepPathDown) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StepPathDown.ProtoReflect.Descriptor instead.
 func (*StepPathDown) Descriptor() ([]byte, []int) {
-	return file_wire_proto_rawDescGZIP(), []int{3}
+	return file_wire_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *StepPathDown) GetDown() string {
@@ -303,36 +669,425 @@ func (x *StepPathDown) GetDown() string {
 	return ""
 }
 
+// StepHeader is the first step of a file, describing how the steps were made.
+type StepHeader struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version  uint32   `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Root     string   `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	Block    []string `protobuf:"bytes,3,rep,name=block,proto3" json:"block,omitempty"`
+	Hash     []string `protobuf:"bytes,4,rep,name=hash,proto3" json:"hash,omitempty"`
+	HashAll  bool     `protobuf:"varint,5,opt,name=hashAll,proto3" json:"hashAll,omitempty"`
+	Hostname string   `protobuf:"bytes,6,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Goos     string   `protobuf:"bytes,7,opt,name=goos,proto3" json:"goos,omitempty"`
+	Goarch   string   `protobuf:"bytes,8,opt,name=goarch,proto3" json:"goarch,omitempty"`
+	// profile is the name of the profile used, if any.
+	Profile string `protobuf:"bytes,9,opt,name=profile,proto3" json:"profile,omitempty"`
+	// hashAlgorithm is the algorithm of StepFile.hash. Empty means xxhash.
+	HashAlgorithm string `protobuf:"bytes,10,opt,name=hashAlgorithm,proto3" json:"hashAlgorithm,omitempty"`
+	// metadata lists the optional metadata collected (e.g. mtime).
+	Metadata []string `protobuf:"bytes,11,rep,name=metadata,proto3" json:"metadata,omitempty"`
+	// maxHashSize is the size above which files are not hashed. 0 means no limit.
+	MaxHashSize uint64 `protobuf:"varint,12,opt,name=maxHashSize,proto3" json:"maxHashSize,omitempty"`
+	// maxDepth is the depth below which directories are not walked. 0 means no limit.
+	MaxDepth uint32 `protobuf:"varint,13,opt,name=maxDepth,proto3" json:"maxDepth,omitempty"`
+	// blockRules and hashRules are gitignore-style rules relative to root.
+	BlockRules []string `protobuf:"bytes,14,rep,name=blockRules,proto3" json:"blockRules,omitempty"`
+	HashRules  []string `protobuf:"bytes,15,rep,name=hashRules,proto3" json:"hashRules,omitempty"`
+	// hinoignore is whether .hinoignore files add block rules for their directory.
+	Hinoignore bool `protobuf:"varint,16,opt,name=hinoignore,proto3" json:"hinoignore,omitempty"`
+	// oneFileSystem is whether directories on other filesystems than root were not walked.
+	OneFileSystem bool `protobuf:"varint,17,opt,name=oneFileSystem,proto3" json:"oneFileSystem,omitempty"`
+	// skipFsTypes are filesystem types (e.g. tmpfs, fuse.*) not walked.
+	SkipFsTypes []string `protobuf:"bytes,18,rep,name=skipFsTypes,proto3" json:"skipFsTypes,omitempty"`
+	// packages is whether files were attributed to packages.
+	Packages bool `protobuf:"varint,19,opt,name=packages,proto3" json:"packages,omitempty"`
+	// packageManagers are the package managers whose databases were found.
+	PackageManagers []string `protobuf:"bytes,20,rep,name=packageManagers,proto3" json:"packageManagers,omitempty"`
+	// source is what the steps were imported from (e.g. mtree), or empty if walked.
+	Source string `protobuf:"bytes,21,opt,name=source,proto3" json:"source,omitempty"`
+	// users and groups are the names of UIDs and GIDs in the root's /etc/passwd and /etc/group, if found.
+	Users  map[uint32]string `protobuf:"bytes,22,rep,name=users,proto3" json:"users,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Groups map[uint32]string `protobuf:"bytes,23,rep,name=groups,proto3" json:"groups,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// contentRules are gitignore-style rules relative to root of files whose content is embedded.
+	ContentRules []string `protobuf:"bytes,24,rep,name=contentRules,proto3" json:"contentRules,omitempty"`
+	// maxContentSize is the size above which content is not embedded.
+	MaxContentSize uint64 `protobuf:"varint,25,opt,name=maxContentSize,proto3" json:"maxContentSize,omitempty"`
+	// store is whether the content of hashed files was copied to a blob store (see StepFile.storeHash).
+	Store bool `protobuf:"varint,26,opt,name=store,proto3" json:"store,omitempty"`
+	// parent is the checksum (see StepTrailer) of the snapshot this is incremental to, or empty if this is a full snapshot.
+	Parent []byte `protobuf:"bytes,27,opt,name=parent,proto3" json:"parent,omitempty"`
+}
+
+func (x *StepHeader) Reset() {
+	*x = StepHeader{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_wire_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StepHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StepHeader) ProtoMessage() {}
+
+func (x *StepHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_wire_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StepHeader.ProtoReflect.Descriptor instead.
+func (*StepHeader) Descriptor() ([]byte, []int) {
+	return file_wire_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StepHeader) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *StepHeader) GetRoot() string {
+	if x != nil {
+		return x.Root
+	}
+	return ""
+}
+
+func (x *StepHeader) GetBlock() []string {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *StepHeader) GetHash() []string {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *StepHeader) GetHashAll() bool {
+	if x != nil {
+		return x.HashAll
+	}
+	return false
+}
+
+func (x *StepHeader) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *StepHeader) GetGoos() string {
+	if x != nil {
+		return x.Goos
+	}
+	return ""
+}
+
+func (x *StepHeader) GetGoarch() string {
+	if x != nil {
+		return x.Goarch
+	}
+	return ""
+}
+
+func (x *StepHeader) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *StepHeader) GetHashAlgorithm() string {
+	if x != nil {
+		return x.HashAlgorithm
+	}
+	return ""
+}
+
+func (x *StepHeader) GetMetadata() []string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *StepHeader) GetMaxHashSize() uint64 {
+	if x != nil {
+		return x.MaxHashSize
+	}
+	return 0
+}
+
+func (x *StepHeader) GetMaxDepth() uint32 {
+	if x != nil {
+		return x.MaxDepth
+	}
+	return 0
+}
+
+func (x *StepHeader) GetBlockRules() []string {
+	if x != nil {
+		return x.BlockRules
+	}
+	return nil
+}
+
+func (x *StepHeader) GetHashRules() []string {
+	if x != nil {
+		return x.HashRules
+	}
+	return nil
+}
+
+func (x *StepHeader) GetHinoignore() bool {
+	if x != nil {
+		return x.Hinoignore
+	}
+	return false
+}
+
+func (x *StepHeader) GetOneFileSystem() bool {
+	if x != nil {
+		return x.OneFileSystem
+	}
+	return false
+}
+
+func (x *StepHeader) GetSkipFsTypes() []string {
+	if x != nil {
+		return x.SkipFsTypes
+	}
+	return nil
+}
+
+func (x *StepHeader) GetPackages() bool {
+	if x != nil {
+		return x.Packages
+	}
+	return false
+}
+
+func (x *StepHeader) GetPackageManagers() []string {
+	if x != nil {
+		return x.PackageManagers
+	}
+	return nil
+}
+
+func (x *StepHeader) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *StepHeader) GetUsers() map[uint32]string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+func (x *StepHeader) GetGroups() map[uint32]string {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *StepHeader) GetContentRules() []string {
+	if x != nil {
+		return x.ContentRules
+	}
+	return nil
+}
+
+func (x *StepHeader) GetMaxContentSize() uint64 {
+	if x != nil {
+		return x.MaxContentSize
+	}
+	return 0
+}
+
+func (x *StepHeader) GetStore() bool {
+	if x != nil {
+		return x.Store
+	}
+	return false
+}
+
+func (x *StepHeader) GetParent() []byte {
+	if x != nil {
+		return x.Parent
+	}
+	return nil
+}
+
 var File_wire_proto protoreflect.FileDescriptor
 
 var file_wire_proto_rawDesc = []byte{
 	0x0a, 0x0a, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x77, 0x69,
-	0x72, 0x65, 0x22, 0x82, 0x01, 0x0a, 0x04, 0x53, 0x74, 0x65, 0x70, 0x12, 0x24, 0x0a, 0x04, 0x66,
+	0x72, 0x65, 0x22, 0xaf, 0x02, 0x0a, 0x04, 0x53, 0x74, 0x65, 0x70, 0x12, 0x24, 0x0a, 0x04, 0x66,
 	0x69, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x77, 0x69, 0x72, 0x65,
 	0x2e, 0x53, 0x74, 0x65, 0x70, 0x46, 0x69, 0x6c, 0x65, 0x48, 0x00, 0x52, 0x04, 0x66, 0x69, 0x6c,
 	0x65, 0x12, 0x22, 0x0a, 0x02, 0x75, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e,
 	0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x50, 0x61, 0x74, 0x68, 0x55, 0x70, 0x48,
 	0x00, 0x52, 0x02, 0x75, 0x70, 0x12, 0x28, 0x0a, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x03, 0x20,
 	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x50,
-	0x61, 0x74, 0x68, 0x44, 0x6f, 0x77, 0x6e, 0x48, 0x00, 0x52, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x42,
-	0x06, 0x0a, 0x04, 0x73, 0x74, 0x65, 0x70, 0x22, 0x98, 0x01, 0x0a, 0x08, 0x53, 0x74, 0x65, 0x70,
-	0x46, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0d, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6f, 0x77, 0x6e, 0x18,
-	0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6f, 0x77, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x67, 0x72,
-	0x70, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x67, 0x72, 0x70, 0x12, 0x12, 0x0a, 0x04,
-	0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65,
-	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x01,
-	0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x68, 0x61, 0x73, 0x68,
-	0x45, 0x72, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x68, 0x61, 0x73, 0x68, 0x45,
-	0x72, 0x72, 0x22, 0x1c, 0x0a, 0x0a, 0x53, 0x74, 0x65, 0x70, 0x50, 0x61, 0x74, 0x68, 0x55, 0x70,
-	0x12, 0x0e, 0x0a, 0x02, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02, 0x75, 0x70,
-	0x22, 0x22, 0x0a, 0x0c, 0x53, 0x74, 0x65, 0x70, 0x50, 0x61, 0x74, 0x68, 0x44, 0x6f, 0x77, 0x6e,
-	0x12, 0x12, 0x0a, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x64, 0x6f, 0x77, 0x6e, 0x42, 0x24, 0x5a, 0x22, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63,
-	0x6f, 0x6d, 0x2f, 0x6e, 0x79, 0x69, 0x79, 0x75, 0x69, 0x2f, 0x68, 0x69, 0x6e, 0x6f, 0x6d, 0x6f,
-	0x72, 0x69, 0x2f, 0x77, 0x69, 0x72, 0x65, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
+	0x61, 0x74, 0x68, 0x44, 0x6f, 0x77, 0x6e, 0x48, 0x00, 0x52, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x12,
+	0x2a, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65,
+	0x72, 0x48, 0x00, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x62,
+	0x6c, 0x6f, 0x62, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x77, 0x69, 0x72, 0x65,
+	0x2e, 0x53, 0x74, 0x65, 0x70, 0x42, 0x6c, 0x6f, 0x62, 0x48, 0x00, 0x52, 0x04, 0x62, 0x6c, 0x6f,
+	0x62, 0x12, 0x2d, 0x0a, 0x07, 0x74, 0x72, 0x61, 0x69, 0x6c, 0x65, 0x72, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x11, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x54, 0x72,
+	0x61, 0x69, 0x6c, 0x65, 0x72, 0x48, 0x00, 0x52, 0x07, 0x74, 0x72, 0x61, 0x69, 0x6c, 0x65, 0x72,
+	0x12, 0x2a, 0x0a, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x10, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x52, 0x65, 0x6d, 0x6f,
+	0x76, 0x65, 0x48, 0x00, 0x52, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x42, 0x06, 0x0a, 0x04,
+	0x73, 0x74, 0x65, 0x70, 0x22, 0x29, 0x0a, 0x0b, 0x53, 0x74, 0x65, 0x70, 0x54, 0x72, 0x61, 0x69,
+	0x6c, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x22,
+	0x20, 0x0a, 0x0a, 0x53, 0x74, 0x65, 0x70, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x22, 0x38, 0x0a, 0x08, 0x53, 0x74, 0x65, 0x70, 0x42, 0x6c, 0x6f, 0x62, 0x12, 0x12, 0x0a,
+	0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73,
+	0x68, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0xc8, 0x04, 0x0a, 0x08,
+	0x53, 0x74, 0x65, 0x70, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x10, 0x0a, 0x03,
+	0x6f, 0x77, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6f, 0x77, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x67, 0x72, 0x70, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x67, 0x72, 0x70,
+	0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04,
+	0x73, 0x69, 0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x18, 0x0a, 0x07,
+	0x68, 0x61, 0x73, 0x68, 0x45, 0x72, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x68,
+	0x61, 0x73, 0x68, 0x45, 0x72, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x6d, 0x74, 0x69, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x6c, 0x69, 0x6e, 0x6b, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x6b,
+	0x12, 0x32, 0x0a, 0x06, 0x78, 0x61, 0x74, 0x74, 0x72, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1a, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x46, 0x69, 0x6c, 0x65,
+	0x2e, 0x58, 0x61, 0x74, 0x74, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06, 0x78, 0x61,
+	0x74, 0x74, 0x72, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x50, 0x6f, 0x69,
+	0x6e, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x50,
+	0x6f, 0x69, 0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x73, 0x54, 0x79, 0x70, 0x65, 0x18, 0x0c,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x73, 0x54, 0x79, 0x70, 0x65, 0x12, 0x18, 0x0a, 0x07,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70,
+	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12, 0x26, 0x0a, 0x0e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e,
+	0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x26,
+	0x0a, 0x0e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72,
+	0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4d,
+	0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x12, 0x39, 0x0a, 0x0d, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67,
+	0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x13, 0x2e,
+	0x77, 0x69, 0x72, 0x65, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x52, 0x0d, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75,
+	0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x64, 0x65, 0x64, 0x18, 0x11, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x64, 0x65, 0x64, 0x12, 0x1c, 0x0a,
+	0x09, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x48, 0x61, 0x73, 0x68, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x09, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x48, 0x61, 0x73, 0x68, 0x1a, 0x39, 0x0a, 0x0b, 0x58,
+	0x61, 0x74, 0x74, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x1c, 0x0a, 0x0a, 0x53, 0x74, 0x65, 0x70, 0x50, 0x61,
+	0x74, 0x68, 0x55, 0x70, 0x12, 0x0e, 0x0a, 0x02, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x02, 0x75, 0x70, 0x22, 0x22, 0x0a, 0x0c, 0x53, 0x74, 0x65, 0x70, 0x50, 0x61, 0x74, 0x68,
+	0x44, 0x6f, 0x77, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x64, 0x6f, 0x77, 0x6e, 0x22, 0xbc, 0x07, 0x0a, 0x0a, 0x53, 0x74, 0x65,
+	0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x68,
+	0x61, 0x73, 0x68, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12,
+	0x18, 0x0a, 0x07, 0x68, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x07, 0x68, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73,
+	0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73,
+	0x74, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x6f, 0x6f, 0x73, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x6f, 0x6f, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x67, 0x6f, 0x61,
+	0x72, 0x63, 0x68, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x67, 0x6f, 0x61, 0x72, 0x63,
+	0x68, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x68,
+	0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x0a, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x68, 0x61, 0x73, 0x68, 0x41, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68,
+	0x6d, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x0b, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x20, 0x0a,
+	0x0b, 0x6d, 0x61, 0x78, 0x48, 0x61, 0x73, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x0c, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x0b, 0x6d, 0x61, 0x78, 0x48, 0x61, 0x73, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x6d, 0x61, 0x78, 0x44, 0x65, 0x70, 0x74, 0x68, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x08, 0x6d, 0x61, 0x78, 0x44, 0x65, 0x70, 0x74, 0x68, 0x12, 0x1e, 0x0a, 0x0a, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x0a, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x68,
+	0x61, 0x73, 0x68, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09,
+	0x68, 0x61, 0x73, 0x68, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x68, 0x69, 0x6e,
+	0x6f, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x18, 0x10, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x68,
+	0x69, 0x6e, 0x6f, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x12, 0x24, 0x0a, 0x0d, 0x6f, 0x6e, 0x65,
+	0x46, 0x69, 0x6c, 0x65, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x18, 0x11, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0d, 0x6f, 0x6e, 0x65, 0x46, 0x69, 0x6c, 0x65, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x12,
+	0x20, 0x0a, 0x0b, 0x73, 0x6b, 0x69, 0x70, 0x46, 0x73, 0x54, 0x79, 0x70, 0x65, 0x73, 0x18, 0x12,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x6b, 0x69, 0x70, 0x46, 0x73, 0x54, 0x79, 0x70, 0x65,
+	0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x18, 0x13, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x08, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x73, 0x12, 0x28, 0x0a,
+	0x0f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4d, 0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x73,
+	0x18, 0x14, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x4d,
+	0x61, 0x6e, 0x61, 0x67, 0x65, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x18, 0x15, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x12,
+	0x31, 0x0a, 0x05, 0x75, 0x73, 0x65, 0x72, 0x73, 0x18, 0x16, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b,
+	0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x2e, 0x55, 0x73, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x75, 0x73, 0x65,
+	0x72, 0x73, 0x12, 0x34, 0x0a, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x18, 0x17, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x77, 0x69, 0x72, 0x65, 0x2e, 0x53, 0x74, 0x65, 0x70, 0x48, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x2e, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x06, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74,
+	0x65, 0x6e, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x18, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x52, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x26, 0x0a, 0x0e,
+	0x6d, 0x61, 0x78, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x19,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x53, 0x69, 0x7a, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x18, 0x1a, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x05, 0x73, 0x74, 0x6f, 0x72, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x61,
+	0x72, 0x65, 0x6e, 0x74, 0x18, 0x1b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x70, 0x61, 0x72, 0x65,
+	0x6e, 0x74, 0x1a, 0x38, 0x0a, 0x0a, 0x55, 0x73, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x39, 0x0a, 0x0b,
+	0x47, 0x72, 0x6f, 0x75, 0x70, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x2a, 0x9f, 0x01, 0x0a, 0x0d, 0x50, 0x61, 0x63, 0x6b,
+	0x61, 0x67, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1e, 0x0a, 0x1a, 0x50, 0x41, 0x43,
+	0x4b, 0x41, 0x47, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x55, 0x4e, 0x53, 0x50,
+	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x18, 0x0a, 0x14, 0x50, 0x41, 0x43,
+	0x4b, 0x41, 0x47, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x4f, 0x57, 0x4e, 0x45,
+	0x44, 0x10, 0x01, 0x12, 0x1b, 0x0a, 0x17, 0x50, 0x41, 0x43, 0x4b, 0x41, 0x47, 0x45, 0x5f, 0x53,
+	0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x56, 0x45, 0x52, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x02,
+	0x12, 0x1b, 0x0a, 0x17, 0x50, 0x41, 0x43, 0x4b, 0x41, 0x47, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54,
+	0x55, 0x53, 0x5f, 0x4d, 0x4f, 0x44, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x03, 0x12, 0x1a, 0x0a,
+	0x16, 0x50, 0x41, 0x43, 0x4b, 0x41, 0x47, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f,
+	0x55, 0x4e, 0x4f, 0x57, 0x4e, 0x45, 0x44, 0x10, 0x04, 0x42, 0x24, 0x5a, 0x22, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6e, 0x79, 0x69, 0x79, 0x75, 0x69, 0x2f, 0x68,
+	0x69, 0x6e, 0x6f, 0x6d, 0x6f, 0x72, 0x69, 0x2f, 0x77, 0x69, 0x72, 0x65, 0x2f, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -347,22 +1102,39 @@ func file_wire_proto_rawDescGZIP() []byte {
 	return file_wire_proto_rawDescData
 }
 
-var file_wire_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_wire_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_wire_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
 var file_wire_proto_goTypes = []interface{}{
-	(*Step)(nil),         // 0: wire.Step
-	(*StepFile)(nil),     // 1: wire.StepFile
-	(*StepPathUp)(nil),   // 2: wire.StepPathUp
-	(*StepPathDown)(nil), // 3: wire.StepPathDown
+	(PackageStatus)(0),   // 0: wire.PackageStatus
+	(*Step)(nil),         // 1: wire.Step
+	(*StepTrailer)(nil),  // 2: wire.StepTrailer
+	(*StepRemove)(nil),   // 3: wire.StepRemove
+	(*StepBlob)(nil),     // 4: wire.StepBlob
+	(*StepFile)(nil),     // 5: wire.StepFile
+	(*StepPathUp)(nil),   // 6: wire.StepPathUp
+	(*StepPathDown)(nil), // 7: wire.StepPathDown
+	(*StepHeader)(nil),   // 8: wire.StepHeader
+	nil,                  // 9: wire.StepFile.XattrsEntry
+	nil,                  // 10: wire.StepHeader.UsersEntry
+	nil,                  // 11: wire.StepHeader.GroupsEntry
 }
 var file_wire_proto_depIdxs = []int32{
-	1, // 0: wire.Step.file:type_name -> wire.StepFile
-	2, // 1: wire.Step.up:type_name -> wire.StepPathUp
-	3, // 2: wire.Step.down:type_name -> wire.StepPathDown
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	5,  // 0: wire.Step.file:type_name -> wire.StepFile
+	6,  // 1: wire.Step.up:type_name -> wire.StepPathUp
+	7,  // 2: wire.Step.down:type_name -> wire.StepPathDown
+	8,  // 3: wire.Step.header:type_name -> wire.StepHeader
+	4,  // 4: wire.Step.blob:type_name -> wire.StepBlob
+	2,  // 5: wire.Step.trailer:type_name -> wire.StepTrailer
+	3,  // 6: wire.Step.remove:type_name -> wire.StepRemove
+	9,  // 7: wire.StepFile.xattrs:type_name -> wire.StepFile.XattrsEntry
+	0,  // 8: wire.StepFile.packageStatus:type_name -> wire.PackageStatus
+	10, // 9: wire.StepHeader.users:type_name -> wire.StepHeader.UsersEntry
+	11, // 10: wire.StepHeader.groups:type_name -> wire.StepHeader.GroupsEntry
+	11, // [11:11] is the sub-list for method output_type
+	11, // [11:11] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
 }
 
 func init() { file_wire_proto_init() }
@@ -384,7 +1156,7 @@ func file_wire_proto_init() {
 			}
 		}
 		file_wire_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*StepFile); i {
+			switch v := v.(*StepTrailer); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -396,7 +1168,7 @@ func file_wire_proto_init() {
 			}
 		}
 		file_wire_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*StepPathUp); i {
+			switch v := v.(*StepRemove); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -408,6 +1180,42 @@ func file_wire_proto_init() {
 			}
 		}
 		file_wire_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StepBlob); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_wire_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StepFile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_wire_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StepPathUp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_wire_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*StepPathDown); i {
 			case 0:
 				return &v.state
@@ -419,24 +1227,41 @@ func file_wire_proto_init() {
 				return nil
 			}
 		}
+		file_wire_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StepHeader); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_wire_proto_msgTypes[0].OneofWrappers = []interface{}{
 		(*Step_File)(nil),
 		(*Step_Up)(nil),
 		(*Step_Down)(nil),
+		(*Step_Header)(nil),
+		(*Step_Blob)(nil),
+		(*Step_Trailer)(nil),
+		(*Step_Remove)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_wire_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   11,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_wire_proto_goTypes,
 		DependencyIndexes: file_wire_proto_depIdxs,
+		EnumInfos:         file_wire_proto_enumTypes,
 		MessageInfos:      file_wire_proto_msgTypes,
 	}.Build()
 	File_wire_proto = out.File
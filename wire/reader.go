@@ -0,0 +1,193 @@
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Reader reads files from the "file" wire format, one at a time.
+type Reader struct {
+	// next returns the next step and its bytes (nil if unknown, e.g. from a channel), or io.EOF.
+	next func() (*pb.Step, []byte, error)
+	// magic is whether the magic was read (or there is none to read).
+	magic bool
+	// sum is the checksum of the steps so far, or nil if unknown.
+	sum hash.Hash
+	// pending is a step read ahead by Header.
+	pending *pb.Step
+
+	dir     string
+	blobs   map[string][]byte
+	header  *pb.StepHeader
+	trailer *pb.StepTrailer
+	// err is returned by every call after the first error (including io.EOF).
+	err error
+}
+
+// NewReader returns a Reader reading from r, which starts with the magic.
+func NewReader(r io.Reader) *Reader {
+	rd := &Reader{dir: "/", blobs: map[string][]byte{}, sum: sha256.New()}
+	rd.next = func() (*pb.Step, []byte, error) {
+		if !rd.magic {
+			var magic [4]byte
+			_, err := io.ReadFull(r, magic[:])
+			if err == io.EOF {
+				// not a valid (empty) file
+				err = io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("read magic: %w", err)
+			}
+			if string(magic[:]) != WireMagic {
+				return nil, nil, errors.New("invalid magic")
+			}
+			rd.sum.Write(magic[:])
+			rd.magic = true
+		}
+		step, raw, err := decodeStep(r)
+		if errors.Is(err, io.EOF) {
+			// only between steps; a partial step is io.ErrUnexpectedEOF
+			return nil, nil, io.EOF
+		}
+		return step, raw, err
+	}
+	return rd
+}
+
+// newChanReader returns a Reader reading steps (after the magic) from in, without checksums.
+func newChanReader(in <-chan *pb.Step) *Reader {
+	return &Reader{
+		next: func() (*pb.Step, []byte, error) {
+			step, ok := <-in
+			if !ok {
+				return nil, nil, io.EOF
+			}
+			return step, nil, nil
+		},
+		magic: true,
+		dir:   "/",
+		blobs: map[string][]byte{},
+	}
+}
+
+// step returns the next step after keeping track of it, or io.EOF after the last step.
+func (r *Reader) step() (*pb.Step, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.pending != nil {
+		step := r.pending
+		r.pending = nil
+		return step, nil
+	}
+	step, err := r.readStep()
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	return step, nil
+}
+
+func (r *Reader) readStep() (*pb.Step, error) {
+	step, raw, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if r.trailer != nil {
+		return nil, errors.New("step after trailer")
+	}
+	if raw == nil {
+		r.sum = nil
+	}
+	switch s := step.Step.(type) {
+	case *pb.Step_Trailer:
+		if r.sum != nil && !bytes.Equal(s.Trailer.Checksum, r.sum.Sum(nil)) {
+			return nil, fmt.Errorf("checksum mismatch: trailer has %x, but content has %x", s.Trailer.Checksum, r.sum.Sum(nil))
+		}
+		r.trailer = s.Trailer
+		return step, nil
+	case *pb.Step_Up:
+		for i := uint32(0); i < s.Up.Up; i++ {
+			r.dir = filepath.Dir(r.dir)
+		}
+	case *pb.Step_Down:
+		r.dir = filepath.Join(r.dir, s.Down.Down)
+	case *pb.Step_Header:
+		r.header = s.Header
+	case *pb.Step_Blob:
+		r.blobs[string(s.Blob.Hash)] = s.Blob.Content
+	case *pb.Step_File, *pb.Step_Remove:
+	default:
+		return nil, errors.New("invalid Step")
+	}
+	if r.sum != nil {
+		r.sum.Write(raw)
+	}
+	return step, nil
+}
+
+// fileInfo returns f, in the current directory, with its embedded content.
+func (r *Reader) fileInfo(f *pb.StepFile) *FileInfo2 {
+	fi := NewFileInfo2(f, r.dir)
+	if fi.Embedded {
+		fi.Content = r.blobs[string(fi.Hash)]
+	}
+	return &fi
+}
+
+// Header returns the header, reading it if no file was read yet, or nil if there is none.
+func (r *Reader) Header() (*pb.StepHeader, error) {
+	if r.header != nil || r.pending != nil {
+		return r.header, nil
+	}
+	step, err := r.step()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := step.Step.(*pb.Step_Header); !ok {
+		r.pending = step
+	}
+	return r.header, nil
+}
+
+// Next returns the next file, or io.EOF after the last one (and the trailer, if any, was verified).
+// Removals in incremental snapshots are skipped.
+func (r *Reader) Next() (*FileInfo2, error) {
+	for {
+		step, err := r.step()
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := step.Step.(*pb.Step_File); ok {
+			return r.fileInfo(f.File), nil
+		}
+	}
+}
+
+// Dir returns the directory of the last file read.
+func (r *Reader) Dir() string {
+	return r.dir
+}
+
+// Trailer returns the trailer, if it was read.
+func (r *Reader) Trailer() *pb.StepTrailer {
+	return r.trailer
+}
+
+// Checksum returns the checksum (see StepTrailer) of what was read before the trailer, or nil if unknown (e.g. from ConvertSteps).
+func (r *Reader) Checksum() []byte {
+	if r.sum == nil {
+		return nil
+	}
+	return r.sum.Sum(nil)
+}
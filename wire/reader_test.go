@@ -0,0 +1,221 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// testFiles returns n files, 100 to a directory 3 deep (in the order EncodeFiles writes them), with every 10th embedding content.
+func testFiles(n int) []FileInfo2 {
+	files := make([]FileInfo2, 0, n)
+	for i := 0; i < n; i++ {
+		fi := FileInfo2{
+			Mode:  0o644,
+			Size:  uint64(i),
+			Name:  fmt.Sprintf("f%d", i),
+			Path:  fmt.Sprintf("/root/d%03d/e%d/g%d", i/1000, i/100%10, i/100%10),
+			Hash:  []byte(fmt.Sprintf("%016x", i)),
+			Mtime: time.Unix(1700000000, int64(i)),
+		}
+		if i%10 == 0 {
+			fi.Embedded = true
+			fi.Content = []byte(fmt.Sprintf("content %d", i%100))
+			fi.Hash = []byte(fmt.Sprintf("%016x", i%100))
+			fi.Size = uint64(len(fi.Content))
+		}
+		files = append(files, fi)
+	}
+	return files
+}
+
+// testSnapshot returns files as a snapshot with a header and trailer.
+func testSnapshot(tb testing.TB, files []FileInfo2) []byte {
+	var buf bytes.Buffer
+	w := NewTrailerWriter(&buf)
+	err := EncodeFiles(w, &pb.StepHeader{Version: HeaderVersion, Root: "/root"}, files)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	err = w.WriteTrailer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	files := testFiles(1000)
+	data := testSnapshot(t, files)
+	r := NewReader(bytes.NewReader(data))
+	h, err := r.Header()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h == nil || h.Root != "/root" {
+		t.Fatalf("header %v", h)
+	}
+	for i := range files {
+		fi, err := r.Next()
+		if err != nil {
+			t.Fatalf("file %d: %s", i, err)
+		}
+		want := &files[i]
+		if filepath.Join(fi.Path, fi.Name) != filepath.Join(want.Path, want.Name) {
+			t.Fatalf("file %d: %s, want %s", i, filepath.Join(fi.Path, fi.Name), filepath.Join(want.Path, want.Name))
+		}
+		if fi.Mode != want.Mode || fi.Size != want.Size || !fi.Mtime.Equal(want.Mtime) || !bytes.Equal(fi.Hash, want.Hash) || !bytes.Equal(fi.Content, want.Content) {
+			t.Fatalf("file %d: %+v, want %+v", i, fi, want)
+		}
+	}
+	_, err = r.Next()
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("after the last file: %v", err)
+	}
+	if r.Trailer() == nil || !bytes.Equal(r.Trailer().Checksum, r.Checksum()) {
+		t.Fatalf("trailer %v, checksum %x", r.Trailer(), r.Checksum())
+	}
+
+	// corrupt a byte in the middle
+	data[len(data)/2] ^= 0xff
+	r = NewReader(bytes.NewReader(data))
+	err = nil
+	for err == nil {
+		_, err = r.Next()
+	}
+	if errors.Is(err, io.EOF) {
+		t.Fatal("corruption not detected")
+	}
+
+	_, err = NewReader(bytes.NewReader(nil)).Next()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("empty: %v", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	data := testSnapshot(t, testFiles(10))
+	r := NewReader(bytes.NewReader(data[:len(data)-3]))
+	var err error
+	for err == nil {
+		_, err = r.Next()
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("truncated: %v", err)
+	}
+}
+
+// TestConvertSteps checks that ConvertSteps stops at the first invalid step, without blocking the sender.
+func TestConvertSteps(t *testing.T) {
+	file := &pb.Step{Step: &pb.Step_File{File: &pb.StepFile{Name: "f", Mode: 0o644}}}
+	steps := []*pb.Step{file, {}, file, file}
+	in := make(chan *pb.Step)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		defer close(in)
+		for _, step := range steps {
+			in <- step
+		}
+	}()
+	out := make(chan FileInfo2)
+	errs := make(chan error)
+	go ConvertSteps(in, out, errs)
+	var files int
+	var failures []error
+	for out != nil || errs != nil {
+		select {
+		case _, ok := <-out:
+			if !ok {
+				out = nil
+				continue
+			}
+			files++
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			failures = append(failures, err)
+		}
+	}
+	<-sent
+	if files != 1 || len(failures) != 1 {
+		t.Errorf("%d files and errors %v, want 1 file and 1 error", files, failures)
+	}
+}
+
+const benchmarkFiles = 100000
+
+func benchmarkSnapshot(b *testing.B) []byte {
+	b.Helper()
+	data := testSnapshot(b, testFiles(benchmarkFiles))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	return data
+}
+
+func BenchmarkReader(b *testing.B) {
+	data := benchmarkSnapshot(b)
+	for i := 0; i < b.N; i++ {
+		r := NewReader(bytes.NewReader(data))
+		n := 0
+		for {
+			_, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			n++
+		}
+		if n != benchmarkFiles {
+			b.Fatalf("%d files, want %d", n, benchmarkFiles)
+		}
+	}
+}
+
+func BenchmarkDecodeStepsConvertSteps(b *testing.B) {
+	data := benchmarkSnapshot(b)
+	for i := 0; i < b.N; i++ {
+		steps := make(chan *pb.Step)
+		out := make(chan FileInfo2)
+		errs := make(chan error)
+		decodeErr := make(chan error, 1)
+		go func() {
+			decodeErr <- DecodeSteps(bytes.NewReader(data), steps)
+		}()
+		go ConvertSteps(steps, out, errs)
+		n := 0
+		for out != nil || errs != nil {
+			select {
+			case _, ok := <-out:
+				if !ok {
+					out = nil
+					continue
+				}
+				n++
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				b.Fatal(err)
+			}
+		}
+		if err := <-decodeErr; !errors.Is(err, io.EOF) {
+			b.Fatal(err)
+		}
+		if n != benchmarkFiles {
+			b.Fatalf("%d files, want %d", n, benchmarkFiles)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package rules implements gitignore-style glob rules.
+//
+// Each line is a pattern, evaluated in order; the last matching pattern wins.
+// Blank lines and lines starting with # are ignored, as are trailing spaces unless escaped with \.
+// A pattern starting with ! negates a previous match.
+// A pattern ending with / only matches directories.
+// A pattern containing / (other than at the end) is relative to the base directory,
+// otherwise it matches a name at any depth.
+// * matches anything except /, ? matches any single character except /,
+// [...] matches a character class (negated with [!...]), and ** as a whole path component
+// matches any number of directories (elsewhere it is *). \ escapes the next character.
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule is a single pattern.
+type Rule struct {
+	Pattern string
+	Negate  bool
+	DirOnly bool
+	// Source and Line locate the rule, e.g. a file path.
+	Source string
+	Line   int
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) String() string {
+	if r.Source == "" {
+		return fmt.Sprintf("%q", r.Pattern)
+	}
+	return fmt.Sprintf("%q (%s:%d)", r.Pattern, r.Source, r.Line)
+}
+
+// Parse parses a single line into a Rule. ok is false if the line is blank or a comment.
+func Parse(line string) (r *Rule, ok bool, err error) {
+	line = trimTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, false, nil
+	}
+	r = &Rule{Pattern: line}
+	if strings.HasPrefix(line, "!") {
+		r.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.DirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return nil, false, fmt.Errorf("empty pattern %q", r.Pattern)
+	}
+	expr, err := globToRegexp(line)
+	if err != nil {
+		return nil, false, fmt.Errorf("pattern %q: %w", r.Pattern, err)
+	}
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+	r.re, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, false, fmt.Errorf("pattern %q: %w", r.Pattern, err)
+	}
+	return r, true, nil
+}
+
+// trimTrailingSpace trims trailing whitespace, except a space escaped with a backslash.
+func trimTrailingSpace(line string) string {
+	line = strings.TrimRight(line, "\r")
+	for len(line) > 0 && (line[len(line)-1] == ' ' || line[len(line)-1] == '\t') {
+		// an odd number of backslashes escapes the space, an even number are escaped backslashes
+		n := 0
+		for n < len(line)-1 && line[len(line)-2-n] == '\\' {
+			n++
+		}
+		if n%2 == 1 {
+			break
+		}
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+func globToRegexp(glob string) (string, error) {
+	b := new(strings.Builder)
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		// ** is only special as a whole path component, otherwise it is *
+		leading := i == 0 || glob[i-1] == '/'
+		switch {
+		case leading && glob[i:] == "**":
+			b.WriteString(".*")
+			i++
+		case leading && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case c == '*':
+			b.WriteString("[^/]*")
+			for i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+			}
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			class, n, err := classToRegexp(glob[i:])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(class)
+			i += n - 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String(), nil
+}
+
+// classToRegexp converts the character class at the start of glob, returning its length in glob.
+// Like other wildcards, a negated class does not match /.
+func classToRegexp(glob string) (string, int, error) {
+	b := new(strings.Builder)
+	b.WriteString("[")
+	i := 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		b.WriteString("^/")
+		i++
+	}
+	for first := true; i < len(glob); first = false {
+		c := glob[i]
+		switch {
+		case c == ']' && !first:
+			b.WriteString("]")
+			return b.String(), i + 1, nil
+		case strings.HasPrefix(glob[i:], "[:"):
+			end := strings.Index(glob[i:], ":]")
+			if end == -1 {
+				return "", 0, fmt.Errorf("unterminated character class")
+			}
+			b.WriteString(glob[i : i+end+2])
+			i += end + 2
+			continue
+		case c == '\\' && i+1 < len(glob):
+			i++
+			c = glob[i]
+			if ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') {
+				b.WriteByte(c)
+			} else {
+				b.WriteString(`\` + string(c))
+			}
+		case c == ']' || c == '[' || c == '\\' || c == '^':
+			b.WriteString(`\` + string(c))
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+	return "", 0, fmt.Errorf("unterminated character class")
+}
+
+// Match reports whether rel, a slash-separated path relative to the base directory, matches r.
+func (r *Rule) Match(rel string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	return r.re.MatchString(rel)
+}
+
+// Rules are rules evaluated in order.
+type Rules []*Rule
+
+// ParseLines parses rules from lines, recording source for each rule.
+func ParseLines(lines []string, source string) (Rules, error) {
+	rs := make(Rules, 0, len(lines))
+	for i, line := range lines {
+		r, ok, err := Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if !ok {
+			continue
+		}
+		r.Source = source
+		r.Line = i + 1
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// Read parses rules from r, one per line.
+func Read(r io.Reader, source string) (Rules, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ParseLines(lines, source)
+}
+
+// ReadFile parses rules from the file at path.
+func ReadFile(path string) (Rules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, path)
+}
+
+// Patterns returns the patterns of rs.
+func (rs Rules) Patterns() []string {
+	patterns := make([]string, len(rs))
+	for i, r := range rs {
+		patterns[i] = r.Pattern
+	}
+	return patterns
+}
+
+// Set is rules relative to a base directory, with rules of parent directories taking lower precedence.
+type Set struct {
+	parent *Set
+	base   string
+	rules  Rules
+}
+
+// NewSet returns a Set of rules relative to base.
+func NewSet(base string, rules Rules) *Set {
+	return &Set{base: base, rules: rules}
+}
+
+// Child returns a Set with rules relative to base, taking precedence over s.
+func (s *Set) Child(base string, rules Rules) *Set {
+	if len(rules) == 0 {
+		return s
+	}
+	return &Set{parent: s, base: base, rules: rules}
+}
+
+// Match returns the last rule matching path (an absolute path), or nil if none matched.
+// path is matched if the returned rule is non-nil and not negated.
+func (s *Set) Match(path string, isDir bool) *Rule {
+	if s == nil {
+		return nil
+	}
+	matched := s.parent.Match(path, isDir)
+	base := strings.TrimSuffix(s.base, "/") + "/"
+	if !strings.HasPrefix(path, base) {
+		return matched
+	}
+	rel := strings.TrimPrefix(path, base)
+	for _, r := range s.rules {
+		if r.Match(rel, isDir) {
+			matched = r
+		}
+	}
+	return matched
+}
+
+// Matches reports whether path matches s.
+func (s *Set) Matches(path string, isDir bool) bool {
+	r := s.Match(path, isDir)
+	return r != nil && !r.Negate
+}
@@ -0,0 +1,257 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	for _, c := range []struct {
+		glob, want string
+	}{
+		{"a.b", `a\.b`},
+		{"*.log", `[^/]*\.log`},
+		{"a?c", `a[^/]c`},
+		{"**", `.*`},
+		{"**/foo", `(?:.*/)?foo`},
+		{"a/**/b", `a/(?:.*/)?b`},
+		{"a/**", `a/.*`},
+		{"a**b", `a[^/]*b`},
+		{"**foo", `[^/]*foo`},
+		{"[abc]", `[abc]`},
+		{"[a-z]x", `[a-z]x`},
+		{"[!a]", `[^/a]`},
+		{"[^a]", `[^/a]`},
+		{"[]a]", `[\]a]`},
+		{"[[:digit:]]", `[[:digit:]]`},
+		{`[\]]`, `[\]]`},
+		{`[\-a]`, `[\-a]`},
+		{`\*`, `\*`},
+		{`\[a]`, `\[a\]`},
+		{`a\`, `a\\`},
+	} {
+		got, err := globToRegexp(c.glob)
+		if err != nil {
+			t.Errorf("%q: %s", c.glob, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%q: %s, want %s", c.glob, got, c.want)
+		}
+	}
+	for _, glob := range []string{"[abc", "[!", "[]", "[[:digit:"} {
+		_, err := globToRegexp(glob)
+		if err == nil {
+			t.Errorf("%q: no error", glob)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		line            string
+		ok              bool
+		negate, dirOnly bool
+	}{
+		{"", false, false, false},
+		{"   ", false, false, false},
+		{"# comment", false, false, false},
+		{`\#file`, true, false, false},
+		{"!keep", true, true, false},
+		{`\!file`, true, false, false},
+		{"dir/", true, false, true},
+		{"!dir/", true, true, true},
+		{"trailing  \t\r", true, false, false},
+	} {
+		r, ok, err := Parse(c.line)
+		if err != nil {
+			t.Errorf("%q: %s", c.line, err)
+			continue
+		}
+		if ok != c.ok {
+			t.Errorf("%q: ok %t, want %t", c.line, ok, c.ok)
+			continue
+		}
+		if ok && (r.Negate != c.negate || r.DirOnly != c.dirOnly) {
+			t.Errorf("%q: negate %t dir only %t, want %t %t", c.line, r.Negate, r.DirOnly, c.negate, c.dirOnly)
+		}
+	}
+	for _, line := range []string{"/", "!", "!/", "[a"} {
+		_, _, err := Parse(line)
+		if err == nil {
+			t.Errorf("%q: no error", line)
+		}
+	}
+}
+
+// TestMatch follows the examples in gitignore(5).
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		// no slash matches at any depth
+		{"*.log", "a.log", false, true},
+		{"*.log", "d/e/a.log", false, true},
+		{"*.log", "a.logx", false, false},
+		{"foo", "foo", true, true},
+		{"foo", "a/foo", false, true},
+		{"foo", "afoo", false, false},
+		// a slash anchors to the base directory
+		{"/foo", "foo", false, true},
+		{"/foo", "a/foo", false, false},
+		{"doc/frotz", "doc/frotz", false, true},
+		{"doc/frotz", "a/doc/frotz", false, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "doc/x/a.txt", false, false},
+		// a trailing slash only matches directories, still at any depth
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "a/build", true, true},
+		{"a/build/", "x/a/build", true, false},
+		// wildcards do not match /
+		{"a*c", "abbc", false, true},
+		{"a*c", "a/c", false, false},
+		{"a?c", "abc", false, true},
+		{"a?c", "a/c", false, false},
+		{"a?c", "ac", false, false},
+		{"a[!b]c", "a/c", false, false},
+		{"a[!b]c", "axc", false, true},
+		{"a[!b]c", "abc", false, false},
+		{"a[bc]d", "acd", false, true},
+		{"a[b-d]e", "ace", false, true},
+		{"a[b-d]e", "aee", false, false},
+		{"[[:digit:]]x", "1x", false, true},
+		{"[[:digit:]]x", "ax", false, false},
+		// ** as a whole component
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"**/foo/bar", "foo/bar", false, true},
+		{"**/foo/bar", "x/foo/bar", false, true},
+		{"abc/**", "abc/x", false, true},
+		{"abc/**", "abc/x/y", false, true},
+		{"abc/**", "abc", true, false},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "a/xb", false, false},
+		{"**", "a/b", false, true},
+		// ** elsewhere is *
+		{"a**b", "axxb", false, true},
+		{"a**b", "a/x/b", false, false},
+		// escapes
+		{`\*`, "*", false, true},
+		{`\*`, "a", false, false},
+		{`\!important`, "!important", false, true},
+		{`\#x`, "#x", false, true},
+		{`\?`, "?", false, true},
+		{`\?`, "a", false, false},
+		{`a\ `, "a ", false, true},
+		{`a\ `, "a", false, false},
+		{`a\\ `, `a\`, false, true},
+		{"a ", "a", false, true},
+		{"a.b", "axb", false, false},
+	} {
+		r, ok, err := Parse(c.pattern)
+		if err != nil || !ok {
+			t.Errorf("%q: ok %t, %v", c.pattern, ok, err)
+			continue
+		}
+		if got := r.Match(c.rel, c.isDir); got != c.want {
+			t.Errorf("%q matching %q (dir %t): %t, want %t", c.pattern, c.rel, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	rs, err := ParseLines([]string{"# comment", "*.log", "", "!keep.log"}, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 2 || rs[0].Line != 2 || rs[1].Line != 4 || rs[1].Source != "src" {
+		t.Fatalf("rules %v", rs)
+	}
+	if s := rs[1].String(); s != `"!keep.log" (src:4)` {
+		t.Errorf("string %s", s)
+	}
+	_, err = ParseLines([]string{"ok", "[bad"}, "src")
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Errorf("error %v", err)
+	}
+
+	rs2, err := Read(strings.NewReader("*.log\r\n!keep.log\n"), "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := rs2.Patterns(); len(p) != 2 || p[0] != "*.log" || p[1] != "!keep.log" {
+		t.Errorf("patterns %q", p)
+	}
+}
+
+func mustParse(t *testing.T, lines ...string) Rules {
+	t.Helper()
+	rs, err := ParseLines(lines, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+// TestSet checks precedence like nested .gitignore files: the last matching rule wins, and rules in deeper directories take precedence.
+func TestSet(t *testing.T) {
+	root := NewSet("/r", mustParse(t, "*.log", "!keep.log", "tmp/", "/top"))
+	sub := root.Child("/r/sub", mustParse(t, "!*.log", "keep.log", "top", "/only"))
+	deeper := sub.Child("/r/sub/deeper", mustParse(t, "*.log"))
+	if root.Child("/r/empty", nil) != root {
+		t.Error("child without rules is not its parent")
+	}
+
+	for _, c := range []struct {
+		set   *Set
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{root, "/r/a.log", false, true},
+		{root, "/r/x/a.log", false, true},
+		{root, "/r/keep.log", false, false},
+		{root, "/r/x/tmp", true, true},
+		{root, "/r/x/tmp", false, false},
+		{root, "/r/top", false, true},
+		{root, "/r/x/top", false, false},
+		// outside the base
+		{root, "/other/a.log", false, false},
+		{root, "/rx/a.log", false, false},
+		// rules in sub take precedence within it
+		{sub, "/r/a.log", false, true},
+		{sub, "/r/sub/a.log", false, false},
+		{sub, "/r/sub/x/a.log", false, false},
+		{sub, "/r/sub/keep.log", false, true},
+		{sub, "/r/sub/top", false, true},
+		{sub, "/r/sub/only", false, true},
+		{sub, "/r/only", false, false},
+		{sub, "/r/sub/x/tmp", true, true},
+		// and deeper ones over sub
+		{deeper, "/r/sub/deeper/a.log", false, true},
+		{deeper, "/r/sub/a.log", false, false},
+		{nil, "/r/a.log", false, false},
+	} {
+		if got := c.set.Matches(c.path, c.isDir); got != c.want {
+			t.Errorf("%s (dir %t): %t, want %t (rule %v)", c.path, c.isDir, got, c.want, c.set.Match(c.path, c.isDir))
+		}
+	}
+
+	r := sub.Match("/r/sub/keep.log", false)
+	if r == nil || r.Pattern != "keep.log" || r.Line != 2 {
+		t.Errorf("rule %v", r)
+	}
+	r = sub.Match("/r/keep.log", false)
+	if r == nil || !r.Negate || r.Pattern != "!keep.log" {
+		t.Errorf("negated rule %v", r)
+	}
+	if r := NewSet("/", mustParse(t, "etc/")).Match("/etc", true); r == nil {
+		t.Error("root base: no match")
+	}
+}
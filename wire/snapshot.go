@@ -0,0 +1,400 @@
+package wire
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Snapshot is a snapshot loaded into memory as a tree, for random access.
+// Paths are relative to the snapshot's root, either as absolute paths (e.g. /etc/passwd) or not (e.g. etc/passwd).
+type Snapshot struct {
+	// Header is the header of the snapshot, or nil if it has none.
+	Header *pb.StepHeader
+	// root is the walked root, which the paths of files are under.
+	root string
+	// nodes are the files, with the root first.
+	nodes []snapshotNode
+	files int
+}
+
+type snapshotNode struct {
+	// fi has an empty Path, which is computed when returned.
+	fi     FileInfo2
+	parent int32
+	// children are sorted by name after loading.
+	children []int32
+	// implicit is whether this directory had no file step, but had files in it.
+	implicit bool
+}
+
+// OpenSnapshot loads the snapshot at path.
+func OpenSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSnapshot(bufio.NewReader(f))
+}
+
+// LoadSnapshot loads the snapshot in r.
+// Later steps for the same path (e.g. from hino-watch) replace earlier ones, and removals are applied.
+func LoadSnapshot(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{
+		root:  "/",
+		nodes: []snapshotNode{{fi: FileInfo2{Mode: fs.ModeDir | 0o755}, parent: -1, implicit: true}},
+	}
+	// names interns names, as many files share them (e.g. README, __init__.py).
+	names := map[string]string{}
+	intern := func(name string) string {
+		if name2, ok := names[name]; ok {
+			return name2
+		}
+		names[name] = name
+		return name
+	}
+	// index is the node of each path relative to the root.
+	index := map[string]int32{"/": 0}
+	var node func(rel string) int32
+	node = func(rel string) int32 {
+		if i, ok := index[rel]; ok {
+			return i
+		}
+		parent := node(path.Dir(rel))
+		i := int32(len(s.nodes))
+		s.nodes = append(s.nodes, snapshotNode{
+			fi:       FileInfo2{Name: intern(path.Base(rel)), Mode: fs.ModeDir | 0o755},
+			parent:   parent,
+			implicit: true,
+		})
+		s.nodes[parent].children = append(s.nodes[parent].children, i)
+		index[rel] = i
+		return i
+	}
+	_, err := Decode(r, Handlers{
+		Header: func(h *pb.StepHeader) error {
+			s.Header = h
+			s.root = h.Root
+			return nil
+		},
+		File: func(fi FileInfo2) error {
+			rel := snapshotPath(InRoot(s.root, filepath.Join(fi.Path, fi.Name)))
+			if rel == "/" {
+				return nil
+			}
+			i := node(rel)
+			n := &s.nodes[i]
+			if n.implicit {
+				s.files++
+			}
+			fi.Path = ""
+			fi.Name = n.fi.Name
+			fi.Link = intern(fi.Link)
+			fi.FsType = intern(fi.FsType)
+			fi.Package = intern(fi.Package)
+			fi.PackageVersion = intern(fi.PackageVersion)
+			fi.PackageManager = intern(fi.PackageManager)
+			n.fi = fi
+			n.implicit = false
+			return nil
+		},
+		Remove: func(p string) error {
+			rel := snapshotPath(InRoot(s.root, p))
+			i, ok := index[rel]
+			if !ok || i == 0 {
+				return nil
+			}
+			parent := &s.nodes[s.nodes[i].parent]
+			for j, c := range parent.children {
+				if c == i {
+					parent.children = append(parent.children[:j], parent.children[j+1:]...)
+					break
+				}
+			}
+			// the nodes stay, unreachable, until the snapshot is dropped
+			prefix := strings.TrimSuffix(rel, "/") + "/"
+			for rel2, j := range index {
+				if rel2 == rel || strings.HasPrefix(rel2, prefix) {
+					if !s.nodes[j].implicit {
+						s.files--
+					}
+					delete(index, rel2)
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range s.nodes {
+		children := s.nodes[i].children
+		sort.Slice(children, func(a, b int) bool {
+			return s.nodes[children[a]].fi.Name < s.nodes[children[b]].fi.Name
+		})
+	}
+	return s, nil
+}
+
+// snapshotPath returns p cleaned as an absolute path.
+func snapshotPath(p string) string {
+	return path.Clean("/" + filepath.ToSlash(p))
+}
+
+// Root returns the root that the paths of files are under (i.e. what was walked).
+func (s *Snapshot) Root() string {
+	return s.root
+}
+
+// Len returns the number of files in s.
+func (s *Snapshot) Len() int {
+	return s.files
+}
+
+// lookup returns the node at p, or -1 if there is none.
+func (s *Snapshot) lookup(p string) int32 {
+	p = snapshotPath(p)
+	i := int32(0)
+	if p == "/" {
+		return i
+	}
+	for _, name := range strings.Split(p[1:], "/") {
+		children := s.nodes[i].children
+		j := sort.Search(len(children), func(j int) bool {
+			return s.nodes[children[j]].fi.Name >= name
+		})
+		if j == len(children) || s.nodes[children[j]].fi.Name != name {
+			return -1
+		}
+		i = children[j]
+	}
+	return i
+}
+
+// relPath returns the path of node i relative to the root.
+func (s *Snapshot) relPath(i int32) string {
+	var parts []string
+	for ; i > 0; i = s.nodes[i].parent {
+		parts = append(parts, s.nodes[i].fi.Name)
+	}
+	for a, b := 0, len(parts)-1; a < b; a, b = a+1, b-1 {
+		parts[a], parts[b] = parts[b], parts[a]
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// fileInfo returns node i with its Path set, as decoded (so from / even under a relative root).
+func (s *Snapshot) fileInfo(i int32) FileInfo2 {
+	fi := s.nodes[i].fi
+	fi.Path = filepath.Join("/", s.root, path.Dir(s.relPath(i)))
+	return fi
+}
+
+// Lookup returns the file at p, and whether it is in s.
+// Directories without a file step (e.g. the root) are not in s.
+func (s *Snapshot) Lookup(p string) (FileInfo2, bool) {
+	i := s.lookup(p)
+	if i <= 0 || s.nodes[i].implicit {
+		return FileInfo2{}, false
+	}
+	return s.fileInfo(i), true
+}
+
+// Children returns the files in the directory dir, sorted by name.
+func (s *Snapshot) Children(dir string) ([]FileInfo2, error) {
+	i := s.lookup(dir)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: fs.ErrNotExist}
+	}
+	if !s.nodes[i].fi.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+	children := s.nodes[i].children
+	files := make([]FileInfo2, 0, len(children))
+	for _, c := range children {
+		if !s.nodes[c].implicit {
+			files = append(files, s.fileInfo(c))
+		}
+	}
+	return files, nil
+}
+
+// Walk calls fn with the path (relative to the root) of each file in s, depth-first and sorted by name.
+// If fn returns fs.SkipDir for a directory, the files in it are skipped.
+func (s *Snapshot) Walk(fn func(rel string, fi FileInfo2) error) error {
+	var walk func(i int32, rel string) error
+	walk = func(i int32, rel string) error {
+		for _, c := range s.nodes[i].children {
+			rel2 := path.Join(rel, s.nodes[c].fi.Name)
+			if !s.nodes[c].implicit {
+				err := fn(rel2, s.fileInfo(c))
+				if err == fs.SkipDir {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+			}
+			err := walk(c, rel2)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(0, "/")
+	if err == fs.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// Stat returns the file at p as an fs.FileInfo, whose Sys is its *FileInfo2.
+// The root (named .) and directories without a file step are reported as directories.
+func (s *Snapshot) Stat(p string) (fs.FileInfo, error) {
+	i := s.lookup(p)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	fi := s.fileInfo(i)
+	if i == 0 {
+		fi.Name = "."
+	}
+	return snapshotFileInfo{&fi}, nil
+}
+
+// FS returns s as an fs.FS (also an fs.StatFS and fs.ReadDirFS), for metadata-only use.
+// Reading a file returns its embedded content, or an error if it has none.
+func (s *Snapshot) FS() fs.FS {
+	return snapshotFS{s}
+}
+
+// snapshotFileInfo is a FileInfo2 as an fs.FileInfo and fs.DirEntry.
+type snapshotFileInfo struct {
+	fi *FileInfo2
+}
+
+func (f snapshotFileInfo) Name() string               { return f.fi.Name }
+func (f snapshotFileInfo) Size() int64                { return int64(f.fi.Size) }
+func (f snapshotFileInfo) Mode() fs.FileMode          { return f.fi.Mode }
+func (f snapshotFileInfo) ModTime() time.Time         { return f.fi.Mtime }
+func (f snapshotFileInfo) IsDir() bool                { return f.fi.Mode.IsDir() }
+func (f snapshotFileInfo) Sys() interface{}           { return f.fi }
+func (f snapshotFileInfo) Type() fs.FileMode          { return f.fi.Mode.Type() }
+func (f snapshotFileInfo) Info() (fs.FileInfo, error) { return f, nil }
+
+type snapshotFS struct {
+	s *Snapshot
+}
+
+// ErrNoContent is returned when reading a file in Snapshot.FS without embedded content.
+var ErrNoContent = errors.New("content not embedded in the snapshot")
+
+func (f snapshotFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	i := f.s.lookup(name)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	info, _ := f.s.Stat(name)
+	return &snapshotFile{s: f.s, i: i, path: name, info: info.(snapshotFileInfo)}, nil
+}
+
+func (f snapshotFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.s.Stat(name)
+}
+
+func (f snapshotFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return f.s.readDir(name)
+}
+
+// readDir returns the entries in dir, including directories without a file step.
+func (s *Snapshot) readDir(dir string) ([]fs.DirEntry, error) {
+	i := s.lookup(dir)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: fs.ErrNotExist}
+	}
+	if !s.nodes[i].fi.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+	entries := make([]fs.DirEntry, len(s.nodes[i].children))
+	for j, c := range s.nodes[i].children {
+		fi := s.fileInfo(c)
+		entries[j] = snapshotFileInfo{&fi}
+	}
+	return entries, nil
+}
+
+// snapshotFile is an open file in Snapshot.FS.
+type snapshotFile struct {
+	s    *Snapshot
+	i    int32
+	path string
+	info snapshotFileInfo
+	// off is the offset into the content.
+	off int
+	// entries are the entries not yet read by ReadDir, once it is called.
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (f *snapshotFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *snapshotFile) Close() error               { return nil }
+
+func (f *snapshotFile) Read(b []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: errors.New("is a directory")}
+	}
+	if !f.info.fi.Embedded {
+		return 0, &fs.PathError{Op: "read", Path: f.path, Err: ErrNoContent}
+	}
+	if f.off >= len(f.info.fi.Content) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.info.fi.Content[f.off:])
+	f.off += n
+	return n, nil
+}
+
+func (f *snapshotFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !f.read {
+		entries, err := f.s.readDir(f.path)
+		if err != nil {
+			return nil, err
+		}
+		f.entries = entries
+		f.read = true
+	}
+	if n <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(f.entries) {
+		n = len(f.entries)
+	}
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+	return entries, nil
+}
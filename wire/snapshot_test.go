@@ -0,0 +1,241 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// testSnapshotTree returns a snapshot of a tree walked from root, written with Writer out of order,
+// with a directory without a file step (/usr), a replaced file, and a removed directory.
+func testSnapshotTree(t *testing.T, root string) *Snapshot {
+	t.Helper()
+	dir := filepath.Join("/", root)
+	mtime := time.Unix(1700000000, 0)
+	regular := func(p, content string) *FileInfo2 {
+		hash, err := HashBytes([]byte(content), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &FileInfo2{Mode: 0o644, Size: uint64(len(content)), Name: filepath.Base(p), Path: filepath.Join(dir, filepath.Dir(p)), Mtime: mtime, Hash: hash, Embedded: true, Content: []byte(content)}
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.WriteHeader(&pb.StepHeader{Version: HeaderVersion, Root: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range []*FileInfo2{
+		regular("usr/bin/ls", "ls"),
+		{Mode: fs.ModeDir | 0o755, Name: "etc", Path: dir, Mtime: mtime},
+		regular("etc/passwd", "old"),
+		{Mode: fs.ModeSymlink | 0o777, Name: "lib", Path: dir, Link: "usr/lib", Mtime: mtime},
+		regular("etc/hosts", "127.0.0.1 localhost\n"),
+		{Mode: fs.ModeDir | 0o755, Name: "tmp", Path: dir, Mtime: mtime},
+		regular("tmp/a/b", "b"),
+		regular("etc/passwd", "root:x:0:0::/root:/bin/sh\n"),
+		regular("empty", ""),
+	} {
+		err = w.Write(fi)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.Remove(filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+var testSnapshotRoots = []string{"/", "/abs/td", "td", "./td"}
+
+func TestSnapshotFS(t *testing.T) {
+	for _, root := range testSnapshotRoots {
+		s := testSnapshotTree(t, root)
+		err := fstest.TestFS(s.FS(), "etc/passwd", "etc/hosts", "usr/bin/ls", "lib", "empty")
+		if err != nil {
+			t.Errorf("%s: %s", root, err)
+		}
+		b, err := fs.ReadFile(s.FS(), "etc/passwd")
+		if err != nil || string(b) != "root:x:0:0::/root:/bin/sh\n" {
+			t.Errorf("%s: read %q, %v", root, b, err)
+		}
+		_, err = fs.Stat(s.FS(), "tmp/a")
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: removed: %v", root, err)
+		}
+	}
+
+	// files without content cannot be read
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.Write(&FileInfo2{Mode: 0o644, Size: 3, Name: "f", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = fs.ReadFile(s.FS(), "f")
+	if !errors.Is(err, ErrNoContent) {
+		t.Errorf("read without content: %v", err)
+	}
+}
+
+func TestSnapshotLookup(t *testing.T) {
+	for _, root := range testSnapshotRoots {
+		s := testSnapshotTree(t, root)
+		if s.Len() != 6 {
+			t.Errorf("%s: %d files, want 6", root, s.Len())
+		}
+		for _, c := range []struct {
+			p    string
+			ok   bool
+			size uint64
+		}{
+			{"/etc/passwd", true, 26},
+			{"etc/passwd", true, 26},
+			{"/etc/../etc/passwd", true, 26},
+			{"/etc//passwd/", true, 26},
+			{"/etc", true, 0},
+			{"/usr/bin/ls", true, 2},
+			// directories without a file step
+			{"/", false, 0},
+			{"/usr", false, 0},
+			// removed
+			{"/tmp", false, 0},
+			{"/tmp/a/b", false, 0},
+			{"/etc/nonexistent", false, 0},
+			{"/etc/passwd/x", false, 0},
+			// paths are relative to the root, not as decoded
+			{filepath.Join("/", root, "etc/passwd"), root == "/", 26},
+		} {
+			fi, ok := s.Lookup(c.p)
+			if ok != c.ok {
+				t.Errorf("%s: %s: found %t, want %t", root, c.p, ok, c.ok)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if fi.Size != c.size {
+				t.Errorf("%s: %s: size %d, want %d", root, c.p, fi.Size, c.size)
+			}
+			// as decoded, from / even under a relative root, so that InRoot undoes it
+			if !filepath.IsAbs(fi.Path) {
+				t.Errorf("%s: %s: path %s is not absolute", root, c.p, fi.Path)
+			}
+			if rel := InRoot(root, filepath.Join(fi.Path, fi.Name)); rel != snapshotPath(c.p) {
+				t.Errorf("%s: %s: path %s name %s, in root %s", root, c.p, fi.Path, fi.Name, rel)
+			}
+		}
+	}
+}
+
+func TestSnapshotChildren(t *testing.T) {
+	for _, root := range testSnapshotRoots {
+		s := testSnapshotTree(t, root)
+		for _, c := range []struct {
+			dir  string
+			want string
+		}{
+			// without usr, which has no file step
+			{"/", "empty etc lib"},
+			{"", "empty etc lib"},
+			{"/etc", "hosts passwd"},
+			{"etc/", "hosts passwd"},
+			{"/usr", ""},
+			{"/usr/bin", "ls"},
+		} {
+			files, err := s.Children(c.dir)
+			if err != nil {
+				t.Errorf("%s: %q: %s", root, c.dir, err)
+				continue
+			}
+			var names []string
+			for _, fi := range files {
+				names = append(names, fi.Name)
+			}
+			if strings.Join(names, " ") != c.want {
+				t.Errorf("%s: %q: children %q, want %s", root, c.dir, names, c.want)
+			}
+		}
+		for _, c := range []struct {
+			dir string
+			err error
+		}{
+			{"/nonexistent", fs.ErrNotExist},
+			{"/tmp", fs.ErrNotExist},
+			{"/etc/passwd", nil},
+		} {
+			_, err := s.Children(c.dir)
+			if err == nil || (c.err != nil && !errors.Is(err, c.err)) {
+				t.Errorf("%s: %s: error %v, want %v", root, c.dir, err, c.err)
+			}
+		}
+	}
+}
+
+func TestSnapshotWalk(t *testing.T) {
+	s := testSnapshotTree(t, "td")
+	var rels []string
+	err := s.Walk(func(rel string, fi FileInfo2) error {
+		if filepath.Base(rel) != fi.Name {
+			t.Errorf("%s: name %s", rel, fi.Name)
+		}
+		rels = append(rels, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/empty /etc /etc/hosts /etc/passwd /lib /usr/bin/ls"; strings.Join(rels, " ") != want {
+		t.Errorf("walked %q, want %s", rels, want)
+	}
+
+	rels = nil
+	err = s.Walk(func(rel string, fi FileInfo2) error {
+		rels = append(rels, rel)
+		if rel == "/etc" {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/empty /etc /lib /usr/bin/ls"; strings.Join(rels, " ") != want {
+		t.Errorf("walked with SkipDir %q, want %s", rels, want)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = s.Walk(func(rel string, fi FileInfo2) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("stopped after %d: %v", n, err)
+	}
+}
@@ -0,0 +1,61 @@
+package wire
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+// Blocked returns whether walking root skips path, as it or an ancestor is blocked by Block, block rules, or .hinoignore files (if enabled).
+// Paths outside root are blocked.
+func (w *Walker) Blocked(root, path string, isDir bool) bool {
+	root = filepath.Clean(root)
+	path = filepath.Clean(path)
+	if path == root {
+		return false
+	}
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	if !strings.HasPrefix(path, prefix) {
+		return true
+	}
+	set := rules.NewSet(root, w.blockRules)
+	if w.hinoignore {
+		set = w.readHinoignore(root, set)
+	}
+	parts := strings.Split(strings.TrimPrefix(path, prefix), "/")
+	dir := root
+	for i, part := range parts {
+		dir = filepath.Join(dir, part)
+		last := i == len(parts)-1
+		if w.isBlocked(dir) || set.Matches(dir, isDir || !last) {
+			return true
+		}
+		if !last && w.hinoignore {
+			set = w.readHinoignore(dir, set)
+		}
+	}
+	return false
+}
+
+// StatFile returns the step for the file at path as walking root would write it, and a blob step if its content is embedded.
+// The step is nil if files of its type are not walked. Mount points are not detected.
+func (w *Walker) StatFile(root, path string) (*pb.StepFile, *pb.StepBlob, error) {
+	root = filepath.Clean(root)
+	path = filepath.Clean(path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, ok := w.fileRes(root, path, info, mountRes{}, rules.NewSet(root, w.hashRules), rules.NewSet(root, w.contentRules))
+	if !ok {
+		return nil, nil, nil
+	}
+	var blob *pb.StepBlob
+	if res.Content != nil {
+		blob = &pb.StepBlob{Hash: res.Hash, Content: res.Content}
+	}
+	return res.stepFile(), blob, nil
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/gammazero/deque"
 	"github.com/nyiyui/opt/hinomori/wire/pb"
+	"github.com/nyiyui/opt/hinomori/wire/rules"
 )
 
 const counterCutoff = 65536
@@ -23,7 +24,6 @@ type WalkStep struct {
 }
 
 type stepRes struct {
-	File    bool
 	Mode    fs.FileMode
 	Size    int64
 	Name    string
@@ -32,73 +32,109 @@ type stepRes struct {
 	AbsPath string
 	Owner   uint32
 	Group   uint32
+	Mtime   int64
+	Link    string
+	Xattrs  map[string][]byte
 
-	Up uint32
+	MountPoint bool
+	FsType     string
 
-	Down string
+	Package        string
+	PackageVersion string
+	PackageManager string
+	PackageStatus  pb.PackageStatus
+
+	// Content is the content to embed, if any.
+	Content []byte
+	// StoreHash is the SHA-256 of the content copied to the blob store, if any.
+	StoreHash []byte
 }
 
 func (w *Walker) Walk2(path string, out io.Writer) error {
+	_, err := w.Walk2Dir(path, out)
+	return err
+}
+
+// Walk2Dir is Walk2, also returning the directory the steps end in, to write more steps after them.
+func (w *Walker) Walk2Dir(path string, out io.Writer) (string, error) {
 	stepRess := make(chan stepRes)
 	go w.walk2(path, stepRess)
+	wr := NewWriterIn(out, "/")
 	for res := range stepRess {
-		if res.Up != 0 {
-			err := EncodeStep(out, &pb.Step{
-				Step: &pb.Step_Up{
-					Up: &pb.StepPathUp{
-						Up: res.Up,
-					},
-				},
-			})
-			if err != nil {
-				return fmt.Errorf("%s up: %w", res.AbsPath, err)
-			}
-		}
-		if res.Down != "" {
-			err := EncodeStep(out, &pb.Step{
-				Step: &pb.Step_Down{
-					Down: &pb.StepPathDown{
-						Down: res.Down,
-					},
-				},
-			})
-			if err != nil {
-				return fmt.Errorf("%s down: %w", res.AbsPath, err)
-			}
-		}
-		if res.File {
-			err := EncodeStep(out, &pb.Step{
-				Step: &pb.Step_File{
-					File: &pb.StepFile{
-						Mode:    uint32(res.Mode),
-						Size:    uint64(res.Size),
-						Name:    res.Name,
-						Hash:    res.Hash,
-						HashErr: res.HashErr,
-						Own:     res.Owner,
-						Grp:     res.Group,
-					},
-				},
-			})
-			if err != nil {
-				return fmt.Errorf("%s file: %w", res.AbsPath, err)
-			}
+		fi := NewFileInfo2(res.stepFile(), filepath.Dir(res.AbsPath))
+		fi.Content = res.Content
+		err := wr.Write(&fi)
+		if err != nil {
+			return "", err
 		}
 	}
 	log.Printf("finished stepRess")
-	return nil
+	return wr.Dir(), nil
+}
+
+// stepFile returns res as a pb.StepFile.
+func (res *stepRes) stepFile() *pb.StepFile {
+	return &pb.StepFile{
+		Mode:    uint32(res.Mode),
+		Size:    uint64(res.Size),
+		Name:    res.Name,
+		Hash:    res.Hash,
+		HashErr: res.HashErr,
+		Own:     res.Owner,
+		Grp:     res.Group,
+		Mtime:   res.Mtime,
+		Link:    res.Link,
+		Xattrs:  res.Xattrs,
+
+		MountPoint: res.MountPoint,
+		FsType:     res.FsType,
+
+		Package:        res.Package,
+		PackageVersion: res.PackageVersion,
+		PackageManager: res.PackageManager,
+		PackageStatus:  res.PackageStatus,
+
+		Embedded:  res.Content != nil,
+		StoreHash: res.StoreHash,
+	}
+}
+
+// PathDelta returns how many directories to go up, then which directories to go down, to go from directory a to b.
+func PathDelta(a, b string) (up uint32, down string) {
+	as := splitPath(a)
+	bs := splitPath(b)
+	lc := common(as, bs)
+	return uint32(len(as) - lc), strings.Join(bs[lc:], string(os.PathSeparator))
+}
+
+func splitPath(p string) []string {
+	p = filepath.Clean(p)
+	if p == string(os.PathSeparator) || p == "." {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(p, string(os.PathSeparator)), string(os.PathSeparator))
 }
 
 func (w *Walker) walk2(path string, stepRess chan<- stepRes) {
 	defer close(stepRess)
+	// names below are cleaned by filepath.Join, so rules must be based on the cleaned root (as in Blocked and Explain)
+	path = filepath.Clean(path)
 
-	stepRess <- stepRes{
-		Down: path,
+	var q deque.Deque[qItem]
+	var rootDev uint64
+	if info, err := os.Stat(path); err != nil {
+		log.Printf("stat %s: %s", path, err)
+	} else {
+		rootDev, _ = statDev(info)
+	}
+	mounts, err := readMountinfoFile()
+	if err != nil {
+		log.Printf("read mountinfo: %s", err)
 	}
 
-	var q deque.Deque[qItem]
-	q.PushBack(qItem{Name: path, First: true})
-	var prevName string
+	q.PushBack(qItem{Name: path, Rules: rules.NewSet(path, w.blockRules), Dev: rootDev})
+	hashSet := rules.NewSet(path, w.hashRules)
+	contentSet := rules.NewSet(path, w.contentRules)
 	counter := 0
 	showCounterNext := 1
 	defer func() {
@@ -123,47 +159,28 @@ func (w *Walker) walk2(path string, stepRess chan<- stepRes) {
 			if err != nil {
 				log.Printf("read %s: %s", item.Name, err)
 			}
+			set := item.Rules
+			if w.hinoignore {
+				set = w.readHinoignore(item.Name, set)
+			}
 			names := make([]string, len(entries))
+			mountRess := make([]mountRes, len(entries))
 			for i, entry := range entries {
 				name := filepath.Join(item.Name, entry.Name())
-				if w.isBlocked(name) {
+				if w.isBlocked(name) || set.Matches(name, entry.IsDir()) {
 					continue
 				}
 				names[i] = name
-				if entry.IsDir() {
-					q.PushBack(qItem{Name: names[i]})
-				}
-			}
-			{
-				var up uint32
-				var down string
-				if prevName != item.Name && !item.First {
-					a := strings.Split(prevName, string(os.PathSeparator))
-					if len(a) == 1 && a[0] == "" {
-						a = []string{}
-					}
-					b := strings.Split(item.Name, string(os.PathSeparator))
-					if len(b) == 1 && b[0] == "" {
-						b = []string{}
-					}
-					lc := common(a, b)
-					down = strings.Join(b[lc:], string(os.PathSeparator))
-					up = uint32(len(a[lc:]))
-					/*
-						log.Printf("prevName %s", prevName)
-						log.Printf("itemName %s", item.Name)
-						log.Printf("down %s", down)
-						log.Printf("up %d", up)
-					*/
+				if !entry.IsDir() {
+					continue
 				}
-				var res stepRes
-				if up != 0 {
-					res.Up = up
+				mountRess[i] = w.mount(name, entry, item.Dev, rootDev, mounts)
+				if mountRess[i].MountPoint {
+					log.Printf("mount point %s (%s), descend: %t", name, mountRess[i].FsType, mountRess[i].Descend)
 				}
-				if down != "" {
-					res.Down = down
+				if mountRess[i].Descend && (w.maxDepth == 0 || item.Depth < w.maxDepth) {
+					q.PushBack(qItem{Name: names[i], Depth: item.Depth + 1, Rules: set, Dev: mountRess[i].Dev})
 				}
-				stepRess <- res
 			}
 			wg.Add(len(entries))
 			for i, entry := range entries {
@@ -178,45 +195,119 @@ func (w *Walker) walk2(path string, stepRess chan<- stepRes) {
 						log.Printf("info %s: %s", name, err)
 						return
 					}
-					if !(entry.IsDir() || info.Mode().IsRegular()) {
+					res, ok := w.fileRes(path, name, info, mountRess[i], hashSet, contentSet)
+					if !ok {
 						return
 					}
-					var hash []byte
-					var hashErr error
-					if info.Size() != 0 && (w.hashAll || w.isHashPath(name)) {
-						hash, hashErr = w.makeHash(name)
-						if hashErr != nil {
-							log.Printf("hash %s: %s", name, hashErr)
-						}
-					}
-					hashErr2 := ""
-					if hashErr != nil {
-						hashErr2 = hashErr.Error()
-						// no recover but should be fine enough
-					}
-					res := stepRes{
-						File:    true,
-						Mode:    info.Mode(),
-						Size:    info.Size(),
-						Name:    entry.Name(),
-						Hash:    hash,
-						HashErr: hashErr2,
-						AbsPath: name,
-					}
-					sys := info.Sys()
-					switch sys := sys.(type) {
-					case *syscall.Stat_t:
-						res.Owner = sys.Uid
-						res.Group = sys.Gid
-					}
 					stepRess <- res
 				}(i, entry)
 			}
-			if item.First {
-				prevName = filepath.Join(item.Name, "..")
-			} else {
-				prevName = item.Name
-			}
 		}()
 	}
 }
+
+// fileRes returns the step for the file at name with info when walking root, or false if its type is not walked.
+func (w *Walker) fileRes(root, name string, info fs.FileInfo, mr mountRes, hashSet, contentSet *rules.Set) (stepRes, bool) {
+	isLink := info.Mode()&fs.ModeSymlink != 0
+	if !(info.IsDir() || info.Mode().IsRegular() || (isLink && w.metadata["links"])) {
+		return stepRes{}, false
+	}
+	var hash, content []byte
+	var err error
+	if info.Mode().IsRegular() && uint64(info.Size()) <= w.contentLimit() && contentSet.Matches(name, false) {
+		// also hashes it, so it is read once
+		content, hash, err = w.readContent(name)
+		if err != nil {
+			log.Printf("content %s: %s", name, err)
+		}
+	}
+	var hashErr error
+	tooLarge := w.maxHashSize != 0 && uint64(info.Size()) > w.maxHashSize
+	if content == nil && info.Size() != 0 && !isLink && !tooLarge && (w.hashAll || w.isHashPath(name) || hashSet.Matches(name, info.IsDir())) {
+		hash, hashErr = w.makeHash(name)
+		if hashErr != nil {
+			log.Printf("hash %s: %s", name, hashErr)
+		}
+	}
+	hashErr2 := ""
+	if hashErr != nil {
+		hashErr2 = hashErr.Error()
+		// no recover but should be fine enough
+	}
+	res := stepRes{
+		Mode:    info.Mode(),
+		Size:    info.Size(),
+		Name:    info.Name(),
+		Hash:    hash,
+		HashErr: hashErr2,
+		AbsPath: name,
+		Content: content,
+
+		MountPoint: mr.MountPoint,
+		FsType:     mr.FsType,
+	}
+	if w.store != nil && hash != nil && info.Mode().IsRegular() {
+		var known []byte
+		if w.hashAlgorithm == "sha256" {
+			known = hash
+		}
+		res.StoreHash, err = w.store.PutFile(name, known)
+		if err != nil {
+			log.Printf("store %s: %s", name, err)
+		}
+	}
+	if w.metadata["mtime"] {
+		res.Mtime = info.ModTime().UnixNano()
+	}
+	if isLink {
+		res.Link, err = os.Readlink(name)
+		if err != nil {
+			log.Printf("readlink %s: %s", name, err)
+		}
+	}
+	if w.metadata["xattrs"] && !isLink {
+		res.Xattrs, err = GetXattrs(name)
+		if err != nil {
+			log.Printf("xattrs %s: %s", name, err)
+		}
+	}
+	if w.packages != nil {
+		o, status, err := w.attribute(root, name, info, hash)
+		if err != nil {
+			log.Printf("attribute %s: %s", name, err)
+		}
+		res.Package = o.Name
+		res.PackageVersion = o.Version
+		res.PackageManager = o.Manager
+		res.PackageStatus = status
+	}
+	sys := info.Sys()
+	switch sys := sys.(type) {
+	case *syscall.Stat_t:
+		res.Owner = sys.Uid
+		res.Group = sys.Gid
+	}
+	return res, true
+}
+
+// readContent reads the content of the regular file at path to embed, and its hash.
+func (w *Walker) readContent(path string) (content, hash []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	// the file may have grown since it was stat'd
+	content, err = io.ReadAll(io.LimitReader(f, int64(w.contentLimit())+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(content)) > w.contentLimit() {
+		return nil, nil, fmt.Errorf("larger than %d bytes", w.contentLimit())
+	}
+	hash, err = HashBytes(content, w.hashAlgorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, hash, nil
+}
@@ -0,0 +1,59 @@
+package wire
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nyiyui/opt/hinomori/wire/rules"
+)
+
+func TestFileResContent(t *testing.T) {
+	root := t.TempDir()
+	rs, err := rules.ParseLines([]string{"*"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWalker()
+	w.HashAll(true)
+	w.ContentRules(rs)
+	w.MaxContentSize(8)
+	contentSet := rules.NewSet(root, w.contentRules)
+	hashSet := rules.NewSet(root, w.hashRules)
+
+	name := filepath.Join(root, "small")
+	err = os.WriteFile(name, []byte("small"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := w.fileRes(root, name, info, mountRes{}, hashSet, contentSet)
+	if !ok {
+		t.Fatal("not walked")
+	}
+	want, err := HashBytes([]byte("small"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Content) != "small" || !bytes.Equal(res.Hash, want) {
+		t.Errorf("content %q hash %x, want %q %x", res.Content, res.Hash, "small", want)
+	}
+
+	// grows past the limit after it was stat'd
+	err = os.WriteFile(name, []byte("no longer small"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ = w.fileRes(root, name, info, mountRes{}, hashSet, contentSet)
+	want, err = HashBytes([]byte("no longer small"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != nil || !bytes.Equal(res.Hash, want) {
+		t.Errorf("grown: content %q hash %x, want none %x", res.Content, res.Hash, want)
+	}
+}
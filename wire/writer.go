@@ -0,0 +1,137 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/nyiyui/opt/hinomori/wire/pb"
+)
+
+// Writer writes files, in any order, in "file" wire format, going up and down between their directories.
+type Writer struct {
+	w *TrailerWriter
+	// dir is the directory the steps are in.
+	dir string
+	// blobs are the hashes of content already written.
+	blobs map[string]bool
+	// started is whether the magic was written (or there is none to write).
+	started bool
+	// continued is whether this continues steps written by something else, so no trailer is written.
+	continued bool
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: NewTrailerWriter(w), dir: "/", blobs: map[string]bool{}}
+}
+
+// NewWriterIn returns a Writer continuing steps already written to w (e.g. by Walker.Walk2Dir), which are in the directory dir.
+// It writes no magic, and Close writes no trailer.
+func NewWriterIn(w io.Writer, dir string) *Writer {
+	return &Writer{w: NewTrailerWriter(w), dir: dir, blobs: map[string]bool{}, started: true, continued: true}
+}
+
+func (w *Writer) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := io.WriteString(w.w, WireMagic)
+	if err != nil {
+		return fmt.Errorf("magic: %w", err)
+	}
+	return nil
+}
+
+// WriteHeader writes h. It must be called before anything else, if at all.
+func (w *Writer) WriteHeader(h *pb.StepHeader) error {
+	if w.started {
+		return errors.New("header after other steps")
+	}
+	err := w.start()
+	if err != nil {
+		return err
+	}
+	return EncodeStep(w.w, &pb.Step{Step: &pb.Step_Header{Header: h}})
+}
+
+// navigate writes the steps to go to the directory dir.
+func (w *Writer) navigate(dir string) error {
+	err := w.start()
+	if err != nil {
+		return err
+	}
+	dir = filepath.Clean(dir)
+	if dir == w.dir {
+		return nil
+	}
+	up, down := PathDelta(w.dir, dir)
+	if up != 0 {
+		err = EncodeStep(w.w, &pb.Step{Step: &pb.Step_Up{Up: &pb.StepPathUp{Up: up}}})
+		if err != nil {
+			return fmt.Errorf("%s up: %w", dir, err)
+		}
+	}
+	if down != "" {
+		err = EncodeStep(w.w, &pb.Step{Step: &pb.Step_Down{Down: &pb.StepPathDown{Down: down}}})
+		if err != nil {
+			return fmt.Errorf("%s down: %w", dir, err)
+		}
+	}
+	w.dir = dir
+	return nil
+}
+
+// Write writes fi, in the (absolute) directory fi.Path, and its content first if embedded and not yet written.
+func (w *Writer) Write(fi *FileInfo2) error {
+	err := w.navigate(fi.Path)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(fi.Path, fi.Name)
+	if fi.Embedded && !w.blobs[string(fi.Hash)] {
+		err = EncodeStep(w.w, &pb.Step{Step: &pb.Step_Blob{Blob: &pb.StepBlob{Hash: fi.Hash, Content: fi.Content}}})
+		if err != nil {
+			return fmt.Errorf("%s blob: %w", path, err)
+		}
+		w.blobs[string(fi.Hash)] = true
+	}
+	err = EncodeStep(w.w, &pb.Step{Step: &pb.Step_File{File: fi.StepFile()}})
+	if err != nil {
+		return fmt.Errorf("%s file: %w", path, err)
+	}
+	return nil
+}
+
+// Remove writes the removal of the file at the (absolute) path, for incremental snapshots.
+func (w *Writer) Remove(path string) error {
+	err := w.navigate(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	err = EncodeStep(w.w, &pb.Step{Step: &pb.Step_Remove{Remove: &pb.StepRemove{Name: filepath.Base(path)}}})
+	if err != nil {
+		return fmt.Errorf("%s remove: %w", path, err)
+	}
+	return nil
+}
+
+// Dir returns the directory the steps are in.
+func (w *Writer) Dir() string {
+	return w.dir
+}
+
+// Close writes the trailer (unless from NewWriterIn), without closing the underlying writer.
+// Nothing may be written after it.
+func (w *Writer) Close() error {
+	if w.continued {
+		return nil
+	}
+	err := w.start()
+	if err != nil {
+		return err
+	}
+	return w.w.WriteTrailer()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package wire
+
+// GetXattrs returns the extended attributes of path, or nil if unsupported.
+func GetXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}